@@ -11,8 +11,9 @@ import (
 func main() {
 	logEmitter := rvm.NewLogEmitter(os.Stdout)
 	rubyVersionParser := rvm.NewRubyVersionParser()
-	gemFileParser := rvm.NewGemfileParser()
+	gemFileParser := rvm.NewGemfileParser(logEmitter)
 	gemFileLockParser := rvm.NewGemfileLockParser()
 	buildpackYMLParser := rvm.NewBuildpackYMLParser()
-	packit.Detect(rvm.Detect(logEmitter, rubyVersionParser, gemFileParser, gemFileLockParser, buildpackYMLParser))
+	toolVersionsParser := rvm.NewToolVersionsParser()
+	packit.Detect(rvm.Detect(logEmitter, rubyVersionParser, gemFileParser, gemFileLockParser, buildpackYMLParser, toolVersionsParser))
 }
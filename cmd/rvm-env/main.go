@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+
+	"github.com/BurntSushi/toml"
+)
+
+// rvm-env is an exec.d executable, it writes the environment variables of the
+// selected Ruby to file descriptor 3 in TOML format, see:
+// https://github.com/buildpacks/spec/blob/main/buildpack.md#execd
+func main() {
+	environment, err := rvm.LaunchEnvironment(rvm.RVMPathsFromEnv(), os.Getenv(rvm.RubySelectionEnv), os.Getenv("PATH"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rvm-env: %s\n", err)
+		os.Exit(1)
+	}
+
+	output := os.NewFile(3, "/dev/fd/3")
+	defer output.Close()
+
+	err = toml.NewEncoder(output).Encode(environment)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rvm-env: %s\n", err)
+		os.Exit(1)
+	}
+}
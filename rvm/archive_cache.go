@@ -0,0 +1,214 @@
+package rvm
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ArchivesLayerName is the name of the cache-only layer that holds the Ruby
+// source archives downloaded by RVM
+const ArchivesLayerName = "archives"
+
+// RubySourceURI is the URI of the MRI source archives, the minor version and
+// the archive name are inserted in place of both %s
+const RubySourceURI = "https://cache.ruby-lang.org/pub/ruby/%s/%s"
+
+// ArchiveCacheSizeEnv is the name of the environment variable that sets the
+// number of archives kept in the archives layer
+const ArchiveCacheSizeEnv = "BP_RVM_ARCHIVE_CACHE_SIZE"
+
+// DefaultArchiveCacheSize is the number of archives kept in the archives
+// layer unless configured otherwise
+const DefaultArchiveCacheSize = 10
+
+// ArchiveCacheSize returns the number of archives to keep in the archives
+// layer
+func ArchiveCacheSize() (int, error) {
+	value, ok := os.LookupEnv(ArchiveCacheSizeEnv)
+	if !ok || value == "" {
+		return DefaultArchiveCacheSize, nil
+	}
+
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid value '%s' for %s, expected a number of archives", value, ArchiveCacheSizeEnv)
+	}
+
+	return size, nil
+}
+
+// PruneArchiveCache updates the order in which the archives in the given
+// directory were used and removes the least recently used archives exceeding
+// the given size. The order is passed in and returned as a list of file
+// names starting with the least recently used archive. Archives that are not
+// part of the order yet and archives of the given Ruby versions count as
+// used just now. The removed archives are returned as well.
+func PruneArchiveCache(path string, order []string, rubyVersions []string, size int) ([]string, []string, error) {
+	files, err := ioutil.ReadDir(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	var archives []string
+	for _, file := range files {
+		archives = append(archives, file.Name())
+	}
+
+	var unused, used []string
+	for _, archive := range order {
+		if contains(archives, archive) && !contains(unused, archive) {
+			unused = append(unused, archive)
+		}
+	}
+	for _, archive := range archives {
+		if !contains(unused, archive) {
+			used = append(used, archive)
+		}
+	}
+
+	var lru []string
+	for _, archive := range unused {
+		if archiveOfRubyVersions(archive, rubyVersions) {
+			used = append(used, archive)
+		} else {
+			lru = append(lru, archive)
+		}
+	}
+	lru = append(lru, used...)
+
+	var removed []string
+	for len(lru) > size {
+		err = os.RemoveAll(filepath.Join(path, lru[0]))
+		if err != nil {
+			return nil, nil, err
+		}
+		removed = append(removed, lru[0])
+		lru = lru[1:]
+	}
+
+	return lru, removed, nil
+}
+
+// archiveOfRubyVersions returns true if the archive file name belongs to one
+// of the given Ruby versions, e.g. "ruby-3.1.2.tar.bz2" to "3.1.2"
+func archiveOfRubyVersions(archive string, rubyVersions []string) bool {
+	for _, rubyVersion := range rubyVersions {
+		if rubyVersion == "" {
+			continue
+		}
+		if strings.ContainsAny(rubyVersion[:1], "0123456789") {
+			rubyVersion = "ruby-" + rubyVersion
+		}
+		if strings.HasPrefix(archive, rubyVersion+".") || strings.HasPrefix(archive, rubyVersion+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// RubySourceArchive returns the name of the source archive of an exact MRI
+// version with the given extension, e.g. "ruby-3.1.2.tar.gz", or an empty
+// string for partial versions and other engines
+func RubySourceArchive(rubyVersion, extension string) string {
+	engine, segments := splitRubyVersion(rubyVersion)
+	if engine != "ruby" || len(segments) != 3 {
+		return ""
+	}
+	return "ruby-" + strings.Join(segments, ".") + "." + extension
+}
+
+// RubySourceArchiveURI returns the URI of a source archive returned by
+// RubySourceArchive
+func RubySourceArchiveURI(archive string) string {
+	segments := strings.SplitN(strings.TrimPrefix(archive, "ruby-"), ".", 3)
+	if len(segments) < 3 {
+		return ""
+	}
+	return fmt.Sprintf(RubySourceURI, segments[0]+"."+segments[1], archive)
+}
+
+// PrefetchArchive downloads the archive at the given URI to the given path in
+// the archives directory unless it has been cached already. It returns true
+// if the archive was downloaded.
+func PrefetchArchive(uri, path string) (bool, error) {
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+
+	// the archive only appears in the cache once it is complete
+	downloadPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".download")
+	defer os.Remove(downloadPath)
+
+	err := Download(uri, downloadPath)
+	if err != nil {
+		return false, err
+	}
+
+	return true, os.Rename(downloadPath, path)
+}
+
+// StoreArchives moves the archives an installer downloaded into the given
+// source directory to the archives directory, unless they are cached there
+// already, and returns their names. Links to cached archives and unpacked
+// sources are left alone.
+func StoreArchives(srcDir, archivesPath string) ([]string, error) {
+	files, err := ioutil.ReadDir(srcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []string
+	for _, file := range files {
+		path := filepath.Join(archivesPath, file.Name())
+		if !file.Mode().IsRegular() || fileExists(path) {
+			continue
+		}
+
+		err = moveFile(filepath.Join(srcDir, file.Name()), path)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, file.Name())
+	}
+	return stored, nil
+}
+
+// moveFile moves a file, copying it if the destination is on another file
+// system. The file only appears at the destination once it is complete.
+func moveFile(source, destination string) error {
+	if err := os.Rename(source, destination); err == nil {
+		return nil
+	}
+
+	copyPath := filepath.Join(filepath.Dir(destination), "."+filepath.Base(destination)+".download")
+	defer os.Remove(copyPath)
+
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	copyFile, err := os.Create(copyPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(copyFile, sourceFile)
+	if closeErr := copyFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(copyPath, destination)
+	if err != nil {
+		return err
+	}
+	return os.Remove(source)
+}
@@ -0,0 +1,187 @@
+package rvm_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testArchiveCache(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		archivesPath string
+	)
+
+	it.Before(func() {
+		var err error
+		archivesPath, err = ioutil.TempDir("", "archives")
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, archive := range []string{"ruby-2.6.5.tar.bz2", "ruby-2.7.6.tar.bz2", "ruby-3.1.2.tar.bz2"} {
+			Expect(ioutil.WriteFile(filepath.Join(archivesPath, archive), []byte{}, 0644)).To(Succeed())
+		}
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(archivesPath)).To(Succeed())
+	})
+
+	context("PruneArchiveCache", func() {
+		it("moves the archives of the requested Ruby versions to the end", func() {
+			order, removed, err := rvm.PruneArchiveCache(archivesPath, []string{"ruby-3.1.2.tar.bz2", "ruby-2.6.5.tar.bz2", "ruby-2.7.6.tar.bz2"}, []string{"3.1.2"}, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(order).To(Equal([]string{"ruby-2.6.5.tar.bz2", "ruby-2.7.6.tar.bz2", "ruby-3.1.2.tar.bz2"}))
+			Expect(removed).To(BeEmpty())
+		})
+
+		it("treats new archives as recently used and forgets missing ones", func() {
+			order, _, err := rvm.PruneArchiveCache(archivesPath, []string{"ruby-2.5.0.tar.bz2", "ruby-2.7.6.tar.bz2"}, nil, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(order).To(Equal([]string{"ruby-2.7.6.tar.bz2", "ruby-2.6.5.tar.bz2", "ruby-3.1.2.tar.bz2"}))
+		})
+
+		it("removes the least recently used archives exceeding the size", func() {
+			order, removed, err := rvm.PruneArchiveCache(archivesPath, []string{"ruby-2.7.6.tar.bz2", "ruby-2.6.5.tar.bz2", "ruby-3.1.2.tar.bz2"}, []string{"2.7"}, 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(order).To(Equal([]string{"ruby-3.1.2.tar.bz2", "ruby-2.7.6.tar.bz2"}))
+			Expect(removed).To(Equal([]string{"ruby-2.6.5.tar.bz2"}))
+
+			Expect(filepath.Join(archivesPath, "ruby-2.6.5.tar.bz2")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(archivesPath, "ruby-2.7.6.tar.bz2")).To(BeAnExistingFile())
+		})
+
+		it("matches archives of other engines", func() {
+			Expect(ioutil.WriteFile(filepath.Join(archivesPath, "jruby-bin-9.3.4.0.tar.gz"), []byte{}, 0644)).To(Succeed())
+
+			order, _, err := rvm.PruneArchiveCache(archivesPath, []string{"ruby-2.6.5.tar.bz2", "ruby-2.7.6.tar.bz2", "ruby-3.1.2.tar.bz2", "jruby-bin-9.3.4.0.tar.gz"}, []string{"jruby-bin-9.3.4.0"}, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(order[len(order)-1]).To(Equal("jruby-bin-9.3.4.0.tar.gz"))
+		})
+
+		it("returns an empty order if the directory does not exist", func() {
+			order, removed, err := rvm.PruneArchiveCache(filepath.Join(archivesPath, "missing"), []string{"ruby-2.6.5.tar.bz2"}, nil, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(order).To(BeEmpty())
+			Expect(removed).To(BeEmpty())
+		})
+	})
+
+	context("ArchiveCacheSize", func() {
+		it.After(func() {
+			Expect(os.Unsetenv(rvm.ArchiveCacheSizeEnv)).To(Succeed())
+		})
+
+		it("returns the default size", func() {
+			Expect(rvm.ArchiveCacheSize()).To(Equal(rvm.DefaultArchiveCacheSize))
+		})
+
+		it("returns the configured size", func() {
+			Expect(os.Setenv(rvm.ArchiveCacheSizeEnv, "3")).To(Succeed())
+			Expect(rvm.ArchiveCacheSize()).To(Equal(3))
+		})
+
+		it("returns an error for invalid sizes", func() {
+			Expect(os.Setenv(rvm.ArchiveCacheSizeEnv, "-1")).To(Succeed())
+			_, err := rvm.ArchiveCacheSize()
+			Expect(err).To(MatchError("invalid value '-1' for BP_RVM_ARCHIVE_CACHE_SIZE, expected a number of archives"))
+		})
+	})
+
+	context("RubySourceArchive", func() {
+		it("returns the archive of exact MRI versions", func() {
+			Expect(rvm.RubySourceArchive("3.1.2", "tar.gz")).To(Equal("ruby-3.1.2.tar.gz"))
+			Expect(rvm.RubySourceArchive("ruby-2.7.6", "tar.bz2")).To(Equal("ruby-2.7.6.tar.bz2"))
+			Expect(rvm.RubySourceArchiveURI("ruby-2.7.6.tar.bz2")).To(Equal("https://cache.ruby-lang.org/pub/ruby/2.7/ruby-2.7.6.tar.bz2"))
+		})
+
+		it("does not know the archive of partial versions and other engines", func() {
+			Expect(rvm.RubySourceArchive("3.1", "tar.gz")).To(BeEmpty())
+			Expect(rvm.RubySourceArchive("jruby-9.4.0.0", "tar.gz")).To(BeEmpty())
+		})
+	})
+
+	context("PrefetchArchive", func() {
+		var server *httptest.Server
+
+		it.Before(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path != "/ruby-3.2.2.tar.gz" {
+					http.NotFound(w, req)
+					return
+				}
+				_, _ = w.Write([]byte("archive"))
+			}))
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("downloads missing archives", func() {
+			path := filepath.Join(archivesPath, "ruby-3.2.2.tar.gz")
+
+			downloaded, err := rvm.PrefetchArchive(server.URL+"/ruby-3.2.2.tar.gz", path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(downloaded).To(BeTrue())
+			Expect(ioutil.ReadFile(path)).To(Equal([]byte("archive")))
+		})
+
+		it("keeps cached archives", func() {
+			downloaded, err := rvm.PrefetchArchive(server.URL+"/ruby-3.1.2.tar.bz2", filepath.Join(archivesPath, "ruby-3.1.2.tar.bz2"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(downloaded).To(BeFalse())
+		})
+
+		it("leaves no partial archive behind", func() {
+			path := filepath.Join(archivesPath, "ruby-3.3.0.tar.gz")
+
+			_, err := rvm.PrefetchArchive(server.URL+"/ruby-3.3.0.tar.gz", path)
+			Expect(err).To(HaveOccurred())
+			Expect(filepath.Join(archivesPath, ".ruby-3.3.0.tar.gz.download")).NotTo(BeAnExistingFile())
+			Expect(path).NotTo(BeAnExistingFile())
+		})
+	})
+
+	context("StoreArchives", func() {
+		var srcDir string
+
+		it.Before(func() {
+			var err error
+			srcDir, err = ioutil.TempDir("", "src")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(srcDir)).To(Succeed())
+		})
+
+		it("moves downloaded archives into the archives directory", func() {
+			Expect(ioutil.WriteFile(filepath.Join(srcDir, "ruby-3.1.4.tar.xz"), []byte("archive"), 0644)).To(Succeed())
+			Expect(os.Mkdir(filepath.Join(srcDir, "ruby-3.1.4"), os.ModePerm)).To(Succeed())
+
+			stored, err := rvm.StoreArchives(srcDir, archivesPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored).To(Equal([]string{"ruby-3.1.4.tar.xz"}))
+			Expect(ioutil.ReadFile(filepath.Join(archivesPath, "ruby-3.1.4.tar.xz"))).To(Equal([]byte("archive")))
+			Expect(filepath.Join(archivesPath, "ruby-3.1.4")).NotTo(BeADirectory())
+		})
+
+		it("leaves links to cached archives alone", func() {
+			Expect(os.Symlink(filepath.Join(archivesPath, "ruby-3.1.2.tar.bz2"), filepath.Join(srcDir, "ruby-3.1.2.tar.bz2"))).To(Succeed())
+
+			stored, err := rvm.StoreArchives(srcDir, archivesPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored).To(BeEmpty())
+			Expect(filepath.Join(archivesPath, "ruby-3.1.2.tar.bz2")).To(BeARegularFile())
+		})
+	})
+}
@@ -20,7 +20,15 @@ func Build(environment EnvironmentConfiguration, logger LogEmitter) packit.Build
 			return packit.BuildResult{}, err
 		}
 
-		buildPackYMLPath := filepath.Join(context.WorkingDir, "buildpack.yml")
+		target := TargetFromEnv(context.Stack)
+		configuration = configuration.ForTarget(target)
+
+		projectPath, err := ProjectPath(context.WorkingDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		buildPackYMLPath := filepath.Join(projectPath, BuildpackYMLSource)
 		buildPackYML, err := BuildpackYMLParse(buildPackYMLPath)
 		if err != nil {
 			logger.Detail("Parsing '%s' failed", buildPackYMLPath)
@@ -33,6 +41,8 @@ func Build(environment EnvironmentConfiguration, logger LogEmitter) packit.Build
 			Context:       context,
 			Environment:   environment,
 			Logger:        logger,
+			ProjectPath:   projectPath,
+			Target:        target,
 		}
 
 		return rvmEnv.BuildRvm()
@@ -8,10 +8,13 @@ import (
 
 // BuildPackYML represents the buildpack.yml file provided by a user / an app
 type BuildPackYML struct {
-	RvmVersion  string `yaml:"rvm_version"`
-	RubyVersion string `yaml:"ruby_version"`
-	NodeVersion string `yaml:"node_version"`
-	RequireNode bool   `yaml:"require_node"`
+	RvmVersion             string        `yaml:"rvm_version"`
+	RubyVersion            string        `yaml:"ruby_version"`
+	NodeVersion            string        `yaml:"node_version"`
+	RequireNode            bool          `yaml:"require_node"`
+	ProjectPath            string        `yaml:"project_path"`
+	AdditionalRubyVersions []string      `yaml:"additional_ruby_versions"`
+	Requires               []Requirement `yaml:"requires"`
 }
 
 // BuildpackYMLParser represents the buildpack.yml parser
@@ -47,6 +47,9 @@ rvm:
   ruby_version: 2.6.1
   node_version: 10.*
   require_node: false
+  additional_ruby_versions:
+  - 2.7.6
+  - 3.0.4
 `), 0644)
 			Expect(err).NotTo(HaveOccurred())
 		})
@@ -58,6 +61,7 @@ rvm:
 			Expect(configData.RubyVersion).To(Equal("2.6.1"))
 			Expect(configData.NodeVersion).To(Equal("10.*"))
 			Expect(configData.RequireNode).To(BeFalse())
+			Expect(configData.AdditionalRubyVersions).To(Equal([]string{"2.7.6", "3.0.4"}))
 		})
 	})
 
@@ -0,0 +1,62 @@
+package rvm
+
+import (
+	"fmt"
+)
+
+// CacheKey represents the properties of the build environment a cached layer
+// depends on. A cached layer is only reused if all of them are unchanged.
+type CacheKey struct {
+	Stack            string
+	Arch             string
+	BuildpackVersion string
+	URI              string
+	Installer        string
+	InstallerVersion string
+	InstallOptions   string
+	OpenSSLVersion   string
+	Patches          string
+}
+
+// fields returns the names of the layer metadata entries and the values of
+// the cache key in a stable order
+func (k CacheKey) fields() [][2]string {
+	return [][2]string{
+		{"stack", k.Stack},
+		{"arch", k.Arch},
+		{"buildpack_version", k.BuildpackVersion},
+		{"uri", k.URI},
+		{"installer", k.Installer},
+		{"installer_version", k.InstallerVersion},
+		{"install_options", k.InstallOptions},
+		{"openssl_version", k.OpenSSLVersion},
+		{"patches", k.Patches},
+	}
+}
+
+// Metadata returns the given layer metadata extended by the cache key
+func (k CacheKey) Metadata(metadata map[string]interface{}) map[string]interface{} {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	for _, field := range k.fields() {
+		metadata[field[0]] = field[1]
+	}
+	return metadata
+}
+
+// Mismatches compares the cache key with the metadata of a cached layer and
+// describes every field that differs, missing fields count as empty
+func (k CacheKey) Mismatches(metadata map[string]interface{}) []string {
+	var mismatches []string
+	for _, field := range k.fields() {
+		cached := ""
+		if value, ok := metadata[field[0]]; ok && value != nil {
+			cached = fmt.Sprintf("%v", value)
+		}
+		if cached != field[1] {
+			mismatches = append(mismatches, fmt.Sprintf("%s changed from '%s' to '%s'", field[0], cached, field[1]))
+		}
+	}
+	return mismatches
+}
@@ -0,0 +1,71 @@
+package rvm_test
+
+import (
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testCacheKey(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		cacheKey rvm.CacheKey
+	)
+
+	it.Before(func() {
+		cacheKey = rvm.CacheKey{
+			Stack:            "io.buildpacks.stacks.bionic",
+			Arch:             "amd64",
+			BuildpackVersion: "1.2.3",
+			URI:              "https://get.rvm.io",
+			Installer:        "rvm",
+			InstallerVersion: "1.29.12",
+			InstallOptions:   "--with-jemalloc",
+		}
+	})
+
+	it("adds the cache key to the layer metadata", func() {
+		Expect(cacheKey.Metadata(map[string]interface{}{"ruby_version": "3.1.2"})).To(Equal(map[string]interface{}{
+			"ruby_version":      "3.1.2",
+			"stack":             "io.buildpacks.stacks.bionic",
+			"arch":              "amd64",
+			"buildpack_version": "1.2.3",
+			"uri":               "https://get.rvm.io",
+			"installer":         "rvm",
+			"installer_version": "1.29.12",
+			"install_options":   "--with-jemalloc",
+			"openssl_version":   "",
+			"patches":           "",
+		}))
+	})
+
+	it("has no mismatches with its own metadata", func() {
+		Expect(cacheKey.Mismatches(cacheKey.Metadata(nil))).To(BeEmpty())
+	})
+
+	it("describes every field that changed", func() {
+		metadata := cacheKey.Metadata(nil)
+		metadata["stack"] = "io.buildpacks.stacks.jammy"
+		metadata["install_options"] = ""
+
+		Expect(cacheKey.Mismatches(metadata)).To(Equal([]string{
+			"stack changed from 'io.buildpacks.stacks.jammy' to 'io.buildpacks.stacks.bionic'",
+			"install_options changed from '' to '--with-jemalloc'",
+		}))
+	})
+
+	it("treats missing fields as empty", func() {
+		cacheKey.InstallOptions = ""
+
+		Expect(cacheKey.Mismatches(map[string]interface{}{"installer": "rvm", "installer_version": "1.29.12"})).To(Equal([]string{
+			"stack changed from '' to 'io.buildpacks.stacks.bionic'",
+			"arch changed from '' to 'amd64'",
+			"buildpack_version changed from '' to '1.2.3'",
+			"uri changed from '' to 'https://get.rvm.io'",
+		}))
+	})
+}
@@ -0,0 +1,143 @@
+package rvm
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ParallelInstallsEnv is the name of the environment variable that sets the
+// number of Rubies compiled in parallel
+const ParallelInstallsEnv = "BP_RVM_PARALLEL_INSTALLS"
+
+// DefaultParallelInstalls is the number of Rubies compiled in parallel
+// unless configured otherwise
+const DefaultParallelInstalls = 1
+
+// ParallelInstalls returns the number of Rubies to compile in parallel
+func ParallelInstalls() (int, error) {
+	value, ok := os.LookupEnv(ParallelInstallsEnv)
+	if !ok || value == "" {
+		return DefaultParallelInstalls, nil
+	}
+
+	parallel, err := strconv.Atoi(value)
+	if err != nil || parallel < 1 {
+		return 0, fmt.Errorf("invalid value '%s' for %s, expected a positive number", value, ParallelInstallsEnv)
+	}
+
+	return parallel, nil
+}
+
+// LimitParallelInstalls returns the number of Rubies an installer compiles in
+// parallel, at most one per requested Ruby. RVM installs every Ruby into the
+// same rvm_path and therefore always compiles one Ruby at a time.
+func LimitParallelInstalls(installerName string, parallelInstalls, rubies int) int {
+	if installerName == RVMInstallerName {
+		return 1
+	}
+	if rubies < parallelInstalls {
+		parallelInstalls = rubies
+	}
+	if parallelInstalls < 1 {
+		return 1
+	}
+	return parallelInstalls
+}
+
+// TaskGroup runs tasks concurrently, at most a limited number at a time, and
+// collects the errors of all failed tasks
+type TaskGroup struct {
+	wait      sync.WaitGroup
+	semaphore chan struct{}
+	mutex     sync.Mutex
+	tasks     int
+	errors    map[int]TaskError
+}
+
+// NewTaskGroup returns a TaskGroup running at most limit tasks at a time, a
+// limit below 1 runs all tasks at once
+func NewTaskGroup(limit int) *TaskGroup {
+	group := &TaskGroup{
+		errors: map[int]TaskError{},
+	}
+	if limit > 0 {
+		group.semaphore = make(chan struct{}, limit)
+	}
+	return group
+}
+
+// Go runs the named task as soon as the limit allows it
+func (g *TaskGroup) Go(name string, task func() error) {
+	g.mutex.Lock()
+	index := g.tasks
+	g.tasks++
+	g.mutex.Unlock()
+
+	g.wait.Add(1)
+	go func() {
+		defer g.wait.Done()
+
+		if g.semaphore != nil {
+			g.semaphore <- struct{}{}
+			defer func() { <-g.semaphore }()
+		}
+
+		if err := task(); err != nil {
+			g.mutex.Lock()
+			g.errors[index] = TaskError{Name: name, Err: err}
+			g.mutex.Unlock()
+		}
+	}()
+}
+
+// Wait waits for all tasks and returns the error of the failed task, or
+// TaskErrors in the order the tasks were started if several of them failed
+func (g *TaskGroup) Wait() error {
+	g.wait.Wait()
+
+	var errors TaskErrors
+	for index := 0; index < g.tasks; index++ {
+		if err, ok := g.errors[index]; ok {
+			errors = append(errors, err)
+		}
+	}
+
+	switch len(errors) {
+	case 0:
+		return nil
+	case 1:
+		return errors[0].Err
+	}
+	return errors
+}
+
+// TaskError represents the error of a named task
+type TaskError struct {
+	Name string
+	Err  error
+}
+
+// Error returns the name of the task followed by its error
+func (e TaskError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Name, e.Err)
+}
+
+// Unwrap returns the error of the task
+func (e TaskError) Unwrap() error {
+	return e.Err
+}
+
+// TaskErrors represents the errors of several failed tasks
+type TaskErrors []TaskError
+
+// Error lists the errors of all failed tasks
+func (e TaskErrors) Error() string {
+	var messages []string
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d tasks failed: %s", len(e), strings.Join(messages, "; "))
+}
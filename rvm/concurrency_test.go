@@ -0,0 +1,120 @@
+package rvm_test
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testConcurrency(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	context("TaskGroup", func() {
+		it("runs all tasks", func() {
+			var count int32
+			tasks := rvm.NewTaskGroup(0)
+			for i := 0; i < 5; i++ {
+				tasks.Go("counting", func() error {
+					atomic.AddInt32(&count, 1)
+					return nil
+				})
+			}
+
+			Expect(tasks.Wait()).To(Succeed())
+			Expect(count).To(Equal(int32(5)))
+		})
+
+		it("runs at most limit tasks at a time", func() {
+			var running, maxRunning int32
+			tasks := rvm.NewTaskGroup(2)
+			for i := 0; i < 6; i++ {
+				tasks.Go("sleeping", func() error {
+					current := atomic.AddInt32(&running, 1)
+					for {
+						observed := atomic.LoadInt32(&maxRunning)
+						if current <= observed || atomic.CompareAndSwapInt32(&maxRunning, observed, current) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+					atomic.AddInt32(&running, -1)
+					return nil
+				})
+			}
+
+			Expect(tasks.Wait()).To(Succeed())
+			Expect(maxRunning).To(BeNumerically("<=", 2))
+		})
+
+		it("returns the error of a single failed task", func() {
+			failure := errors.New("download failed")
+			tasks := rvm.NewTaskGroup(0)
+			tasks.Go("downloading", func() error { return failure })
+			tasks.Go("importing", func() error { return nil })
+
+			Expect(tasks.Wait()).To(Equal(failure))
+		})
+
+		it("aggregates the errors of several failed tasks in the order they were started", func() {
+			tasks := rvm.NewTaskGroup(0)
+			tasks.Go("downloading", func() error {
+				time.Sleep(10 * time.Millisecond)
+				return errors.New("download failed")
+			})
+			tasks.Go("importing", func() error { return errors.New("import failed") })
+
+			err := tasks.Wait()
+			Expect(err).To(MatchError("2 tasks failed: downloading: download failed; importing: import failed"))
+
+			var taskErrors rvm.TaskErrors
+			Expect(errors.As(err, &taskErrors)).To(BeTrue())
+			Expect(taskErrors[1].Name).To(Equal("importing"))
+		})
+	})
+
+	context("ParallelInstalls", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("BP_RVM_PARALLEL_INSTALLS")).To(Succeed())
+		})
+
+		it("compiles one Ruby at a time by default", func() {
+			Expect(rvm.ParallelInstalls()).To(Equal(1))
+		})
+
+		it("reads BP_RVM_PARALLEL_INSTALLS", func() {
+			Expect(os.Setenv("BP_RVM_PARALLEL_INSTALLS", "3")).To(Succeed())
+			Expect(rvm.ParallelInstalls()).To(Equal(3))
+		})
+
+		it("returns an error for invalid values", func() {
+			Expect(os.Setenv("BP_RVM_PARALLEL_INSTALLS", "0")).To(Succeed())
+
+			_, err := rvm.ParallelInstalls()
+			Expect(err).To(MatchError("invalid value '0' for BP_RVM_PARALLEL_INSTALLS, expected a positive number"))
+		})
+	})
+
+	context("LimitParallelInstalls", func() {
+		it("limits RVM to one Ruby at a time", func() {
+			Expect(rvm.LimitParallelInstalls("rvm", 4, 3)).To(Equal(1))
+		})
+
+		it("compiles Rubies in parallel using ruby-build and ruby-install", func() {
+			Expect(rvm.LimitParallelInstalls("ruby-build", 4, 3)).To(Equal(3))
+			Expect(rvm.LimitParallelInstalls("ruby-install", 2, 3)).To(Equal(2))
+		})
+
+		it("compiles at least one Ruby", func() {
+			Expect(rvm.LimitParallelInstalls("ruby-build", 4, 0)).To(Equal(1))
+		})
+	})
+}
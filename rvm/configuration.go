@@ -1,8 +1,11 @@
 package rvm
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 )
@@ -10,11 +13,79 @@ import (
 // Configuration represents this buildpack's configuration read from a table
 // named "configuration"
 type Configuration struct {
-	URI                string `toml:"uri"`
-	DefaultRVMVersion  string `toml:"default_rvm_version"`
-	DefaultRubyVersion string `toml:"default_ruby_version"`
-	DefaultNodeVersion string `toml:"default_node_version"`
-	DefaultRequireNode bool   `toml:"default_require_node"`
+	URI                string   `toml:"uri"`
+	DefaultRVMVersion  string   `toml:"default_rvm_version"`
+	DefaultRubyVersion string   `toml:"default_ruby_version"`
+	DefaultNodeVersion string   `toml:"default_node_version"`
+	DefaultRequireNode bool     `toml:"default_require_node"`
+	VersionSourceOrder []string `toml:"version_source_order"`
+
+	VerifiedExtensionList []string `toml:"verified_extensions"`
+
+	RVMKeyFingerprints []string `toml:"rvm_key_fingerprints"`
+
+	Installer          string `toml:"ruby_installer"`
+	RubyBuildVersion   string `toml:"ruby_build_version"`
+	RubyInstallVersion string `toml:"ruby_install_version"`
+
+	OpenSSLVersion string `toml:"openssl_version"`
+	OpenSSLURI     string `toml:"openssl_uri"`
+	OpenSSLSHA256  string `toml:"openssl_sha256"`
+
+	InstallOptionList []string `toml:"install_options"`
+	RubyBinaryURI     string   `toml:"ruby_binary_uri"`
+
+	Targets []TargetConfiguration `toml:"targets"`
+}
+
+// VersionSourcesEnv is the name of the environment variable that overrides
+// the precedence of the Ruby version sources
+const VersionSourcesEnv = "BP_RVM_VERSION_SOURCES"
+
+// VersionEnv is the name of the environment variable that sets the Ruby
+// version, it is also the name of the corresponding version source
+const VersionEnv = "BP_RVM_VERSION"
+
+// AdditionalRubiesEnv is the name of the environment variable that lists
+// Ruby versions to install next to the primary one
+const AdditionalRubiesEnv = "BP_RVM_ADDITIONAL_RUBIES"
+
+// InstallOptionsEnv is the name of the environment variable that lists
+// additional options passed to "rvm install", e.g. "--with-jemalloc"
+const InstallOptionsEnv = "BP_RVM_INSTALL_OPTIONS"
+
+// BuildEnv and LaunchEnv are the names of the environment variables that
+// select whether Ruby is needed during the build phase of subsequent
+// buildpacks and at launch
+const (
+	BuildEnv  = "BP_RVM_BUILD"
+	LaunchEnv = "BP_RVM_LAUNCH"
+)
+
+// Names of the supported Ruby version sources
+const (
+	EnvironmentSource  = VersionEnv
+	BuildpackYMLSource = "buildpack.yml"
+	RubyVersionSource  = ".ruby-version"
+	ToolVersionsSource = ".tool-versions"
+	GemfileSource      = "Gemfile"
+	GemfileLockSource  = "Gemfile.lock"
+)
+
+// DefaultVersionSource is reported as the version source if none of the
+// version sources specified a Ruby version and the default Ruby version from
+// buildpack.toml is used
+const DefaultVersionSource = "rvm-cnb"
+
+// DefaultVersionSourceOrder lists the Ruby version sources in order of
+// precedence, the first one to specify a Ruby version wins
+var DefaultVersionSourceOrder = []string{
+	EnvironmentSource,
+	BuildpackYMLSource,
+	RubyVersionSource,
+	ToolVersionsSource,
+	GemfileSource,
+	GemfileLockSource,
 }
 
 // MetaData represents this buildpack's metadata
@@ -39,3 +110,128 @@ func ReadConfiguration(cnbPath string) (Configuration, error) {
 
 	return meta.Metadata.Configuration, nil
 }
+
+// VersionSources returns the Ruby version sources to consult in order of
+// precedence. The order can be set using the environment variable
+// BP_RVM_VERSION_SOURCES or "version_source_order" in buildpack.toml, sources
+// missing from the list are disabled. BP_RVM_VERSION is an explicit request
+// of the user and therefore always consulted first.
+func (c Configuration) VersionSources() ([]string, error) {
+	sources := DefaultVersionSourceOrder
+	if c.VersionSourceOrder != nil {
+		sources = c.VersionSourceOrder
+	}
+	if value, ok := os.LookupEnv(VersionSourcesEnv); ok && value != "" {
+		sources = nil
+		for _, source := range strings.Split(value, ",") {
+			if source = strings.TrimSpace(source); source != "" && source != "none" {
+				sources = append(sources, source)
+			}
+		}
+	}
+
+	for i, source := range sources {
+		if !contains(DefaultVersionSourceOrder, source) {
+			return nil, fmt.Errorf("unknown Ruby version source '%s', supported sources are: %s", source, strings.Join(DefaultVersionSourceOrder, ", "))
+		}
+		if contains(sources[:i], source) {
+			return nil, fmt.Errorf("version source '%s' is listed more than once", source)
+		}
+	}
+
+	ordered := []string{EnvironmentSource}
+	for _, source := range sources {
+		if source != EnvironmentSource {
+			ordered = append(ordered, source)
+		}
+	}
+
+	return ordered, nil
+}
+
+// ForTarget returns the configuration with the settings of the first
+// matching entry of "targets" in buildpack.toml applied
+func (c Configuration) ForTarget(target Target) Configuration {
+	for _, targetConfiguration := range c.Targets {
+		if !targetConfiguration.Matches(target) {
+			continue
+		}
+		if targetConfiguration.DefaultRubyVersion != "" {
+			c.DefaultRubyVersion = targetConfiguration.DefaultRubyVersion
+		}
+		if targetConfiguration.RubyBinaryURI != "" {
+			c.RubyBinaryURI = targetConfiguration.RubyBinaryURI
+		}
+		c.InstallOptionList = append(append([]string{}, c.InstallOptionList...), targetConfiguration.InstallOptions...)
+		break
+	}
+	return c
+}
+
+// InstallOptions returns the additional options passed to "rvm install", the
+// options from buildpack.toml followed by the ones in BP_RVM_INSTALL_OPTIONS
+func (c Configuration) InstallOptions() []string {
+	return append(append([]string{}, c.InstallOptionList...), strings.Fields(os.Getenv(InstallOptionsEnv))...)
+}
+
+// InstallerName returns the name of the Ruby installer backend. It can be set
+// using the environment variable BP_RUBY_INSTALLER or "ruby_installer" in
+// buildpack.toml and defaults to RVM.
+func (c Configuration) InstallerName() (string, error) {
+	name := RVMInstallerName
+	if c.Installer != "" {
+		name = c.Installer
+	}
+	if value := os.Getenv(RubyInstallerEnv); value != "" {
+		name = value
+	}
+
+	if !contains(RubyInstallerNames, name) {
+		return "", fmt.Errorf("unknown Ruby installer '%s', supported installers are: %s", name, strings.Join(RubyInstallerNames, ", "))
+	}
+	return name, nil
+}
+
+// RVMReleaseURI returns the URI of the RVM release archive of the given
+// version, "{version}" in the configured URI is replaced by the version
+func (c Configuration) RVMReleaseURI(version string) string {
+	return strings.ReplaceAll(c.URI, "{version}", version)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// LayerFlags returns whether Ruby is required during build and at launch,
+// both default to true and can be disabled using BP_RVM_BUILD=false or
+// BP_RVM_LAUNCH=false, e.g. for build-only tooling images
+func LayerFlags() (bool, bool, error) {
+	flag := func(name string) (bool, error) {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return true, nil
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid value '%s' for %s, expected true or false", value, name)
+		}
+		return enabled, nil
+	}
+
+	build, err := flag(BuildEnv)
+	if err != nil {
+		return false, false, err
+	}
+
+	launch, err := flag(LaunchEnv)
+	if err != nil {
+		return false, false, err
+	}
+
+	return build, launch, nil
+}
@@ -70,4 +70,142 @@ func testConfiguration(t *testing.T, context spec.G, it spec.S) {
 			Expect(os.RemoveAll(cnbDir)).To(Succeed())
 		})
 	})
+
+	context("RVMReleaseURI", func() {
+		it("inserts the RVM version into the URI", func() {
+			configuration := rvm.Configuration{URI: "https://github.com/rvm/rvm/releases/download/{version}/{version}.tar.gz"}
+			Expect(configuration.RVMReleaseURI("1.29.12")).To(Equal("https://github.com/rvm/rvm/releases/download/1.29.12/1.29.12.tar.gz"))
+		})
+	})
+
+	context("InstallOptions", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("BP_RVM_INSTALL_OPTIONS")).To(Succeed())
+		})
+
+		it("returns no options by default", func() {
+			Expect(rvm.Configuration{}.InstallOptions()).To(BeEmpty())
+		})
+
+		it("splits the options at whitespace", func() {
+			Expect(os.Setenv("BP_RVM_INSTALL_OPTIONS", " --with-jemalloc  --disable-install-doc ")).To(Succeed())
+			Expect(rvm.Configuration{}.InstallOptions()).To(Equal([]string{"--with-jemalloc", "--disable-install-doc"}))
+		})
+
+		it("appends the options from the environment to the configured ones", func() {
+			Expect(os.Setenv("BP_RVM_INSTALL_OPTIONS", "--with-jemalloc")).To(Succeed())
+			Expect(rvm.Configuration{InstallOptionList: []string{"--disable-install-doc"}}.InstallOptions()).To(Equal([]string{"--disable-install-doc", "--with-jemalloc"}))
+		})
+	})
+
+	context("ForTarget", func() {
+		var configuration rvm.Configuration
+
+		it.Before(func() {
+			configuration = rvm.Configuration{
+				DefaultRubyVersion: "2.7.1",
+				InstallOptionList:  []string{"--disable-install-doc"},
+				Targets: []rvm.TargetConfiguration{
+					{Stacks: []string{"io.buildpacks.stacks.jammy"}, Distro: "ubuntu", DistroVersion: "22.04", DefaultRubyVersion: "3.2.2", InstallOptions: []string{"--with-jemalloc"}},
+					{Distro: "ubuntu", DefaultRubyVersion: "3.1.4", RubyBinaryURI: "https://rubies.example.com"},
+				},
+			}
+		})
+
+		it("applies the settings of the matching stack", func() {
+			configuration = configuration.ForTarget(rvm.Target{Stack: "io.buildpacks.stacks.jammy"})
+			Expect(configuration.DefaultRubyVersion).To(Equal("3.2.2"))
+			Expect(configuration.InstallOptionList).To(Equal([]string{"--disable-install-doc", "--with-jemalloc"}))
+			Expect(configuration.RubyBinaryURI).To(BeEmpty())
+		})
+
+		it("matches targets by their distribution", func() {
+			Expect(configuration.ForTarget(rvm.Target{Distro: "ubuntu", DistroVersion: "22.04"}).DefaultRubyVersion).To(Equal("3.2.2"))
+
+			configuration = configuration.ForTarget(rvm.Target{Distro: "ubuntu", DistroVersion: "24.04"})
+			Expect(configuration.DefaultRubyVersion).To(Equal("3.1.4"))
+			Expect(configuration.RubyBinaryURI).To(Equal("https://rubies.example.com"))
+		})
+
+		it("keeps the general settings for other targets", func() {
+			configuration = configuration.ForTarget(rvm.Target{Stack: "io.buildpacks.stacks.bionic", Distro: "debian"})
+			Expect(configuration.DefaultRubyVersion).To(Equal("2.7.1"))
+			Expect(configuration.InstallOptionList).To(Equal([]string{"--disable-install-doc"}))
+		})
+	})
+
+	context("LayerFlags", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("BP_RVM_BUILD")).To(Succeed())
+			Expect(os.Unsetenv("BP_RVM_LAUNCH")).To(Succeed())
+		})
+
+		it("requires Ruby during build and at launch by default", func() {
+			build, launch, err := rvm.LayerFlags()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(build).To(BeTrue())
+			Expect(launch).To(BeTrue())
+		})
+
+		it("disables launch when BP_RVM_LAUNCH is false", func() {
+			Expect(os.Setenv("BP_RVM_LAUNCH", "false")).To(Succeed())
+
+			build, launch, err := rvm.LayerFlags()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(build).To(BeTrue())
+			Expect(launch).To(BeFalse())
+		})
+
+		it("returns an error for invalid values", func() {
+			Expect(os.Setenv("BP_RVM_BUILD", "sometimes")).To(Succeed())
+
+			_, _, err := rvm.LayerFlags()
+			Expect(err).To(MatchError("invalid value 'sometimes' for BP_RVM_BUILD, expected true or false"))
+		})
+	})
+
+	context("VersionSources", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("BP_RVM_VERSION_SOURCES")).To(Succeed())
+		})
+
+		it("returns the default order of precedence", func() {
+			sources, err := rvm.Configuration{}.VersionSources()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(Equal([]string{"BP_RVM_VERSION", "buildpack.yml", ".ruby-version", ".tool-versions", "Gemfile", "Gemfile.lock"}))
+		})
+
+		it("returns the order configured in buildpack.toml", func() {
+			sources, err := rvm.Configuration{VersionSourceOrder: []string{"Gemfile.lock", "Gemfile"}}.VersionSources()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(Equal([]string{"BP_RVM_VERSION", "Gemfile.lock", "Gemfile"}))
+		})
+
+		it("prefers the order set by BP_RVM_VERSION_SOURCES", func() {
+			Expect(os.Setenv("BP_RVM_VERSION_SOURCES", " .ruby-version, buildpack.yml ")).To(Succeed())
+
+			sources, err := rvm.Configuration{VersionSourceOrder: []string{"Gemfile.lock", "Gemfile"}}.VersionSources()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(Equal([]string{"BP_RVM_VERSION", ".ruby-version", "buildpack.yml"}))
+		})
+
+		it("always consults BP_RVM_VERSION first", func() {
+			sources, err := rvm.Configuration{VersionSourceOrder: []string{"Gemfile", "BP_RVM_VERSION", ".ruby-version"}}.VersionSources()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(Equal([]string{"BP_RVM_VERSION", "Gemfile", ".ruby-version"}))
+		})
+
+		it("disables all other sources when BP_RVM_VERSION_SOURCES is none", func() {
+			Expect(os.Setenv("BP_RVM_VERSION_SOURCES", "none")).To(Succeed())
+
+			sources, err := rvm.Configuration{}.VersionSources()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(Equal([]string{"BP_RVM_VERSION"}))
+		})
+
+		it("returns an error when a source is listed twice", func() {
+			_, err := rvm.Configuration{VersionSourceOrder: []string{"Gemfile", "Gemfile"}}.VersionSources()
+			Expect(err).To(MatchError("version source 'Gemfile' is listed more than once"))
+		})
+	})
 }
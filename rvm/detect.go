@@ -3,6 +3,7 @@ package rvm
 import (
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/paketo-buildpacks/packit/v2"
 )
@@ -12,10 +13,18 @@ type VersionParser interface {
 	ParseVersion(path string) (version string, err error)
 }
 
+// RequirementParser represents a parser for files like Gemfiles that may
+// request a range of Ruby versions instead of a single one
+type RequirementParser interface {
+	ParseRequirement(path string) (version string, requirement string, err error)
+}
+
 // BuildPlanMetadata represents this buildpack's metadata
 type BuildPlanMetadata struct {
 	RubyVersion   string `toml:"ruby_version"`
 	VersionSource string `toml:"version_source"`
+	Build         bool   `toml:"build"`
+	Launch        bool   `toml:"launch"`
 }
 
 // VersionParserEnv represents an environment that contains everything that is
@@ -40,91 +49,180 @@ func ParseVersion(env VersionParserEnv, version *string) error {
 	return err
 }
 
+// ParseRequirement works like ParseVersion but additionally returns the
+// requirement the version was derived from if the parser supports it
+func ParseRequirement(env VersionParserEnv, version, requirement *string) error {
+	parser, ok := env.Parser.(RequirementParser)
+	if !ok {
+		return ParseVersion(env, version)
+	}
+
+	fullPath := filepath.Join(env.Context.WorkingDir, env.Path)
+	parseResultRubyVersion, parseResultRequirement, err := parser.ParseRequirement(fullPath)
+	if err == nil && parseResultRubyVersion != "" {
+		*version, *requirement = parseResultRubyVersion, parseResultRequirement
+		if *requirement != "" {
+			env.Logger.Detail("Found Ruby requirement in %s: %s", fullPath, *requirement)
+		}
+		env.Logger.Detail("Found Ruby version in %s: %s", fullPath, *version)
+		return nil
+	}
+	return err
+}
+
 // Detect whether this buildpack should install RVM
-func Detect(logger LogEmitter, rubyVersionParser VersionParser, gemFileParser VersionParser, gemFileLockParser VersionParser, buildpackYMLParser VersionParser) packit.DetectFunc {
+func Detect(logger LogEmitter, rubyVersionParser VersionParser, gemFileParser VersionParser, gemFileLockParser VersionParser, buildpackYMLParser VersionParser, toolVersionsParser VersionParser) packit.DetectFunc {
 	return func(context packit.DetectContext) (packit.DetectResult, error) {
-		_, err := os.Stat(filepath.Join(context.WorkingDir, "Gemfile"))
-		if os.IsNotExist(err) {
+		configuration, err := ReadConfiguration(context.CNBPath)
+		if err != nil {
 			return packit.DetectResult{}, err
 		}
+		configuration = configuration.ForTarget(TargetFromEnv(context.Stack))
 
-		configuration, err := ReadConfiguration(context.CNBPath)
+		versionSources, err := configuration.VersionSources()
 		if err != nil {
 			return packit.DetectResult{}, err
 		}
+		logger.Detail("Ruby version sources in order of precedence: %s", strings.Join(versionSources, ", "))
 
-		rubyVersion := configuration.DefaultRubyVersion
+		projectPath, err := ProjectPath(context.WorkingDir)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+		if projectPath != context.WorkingDir {
+			logger.Detail("Using project path: %s", projectPath)
+		}
 
-		// NOTE: the order of the parsers is important, the last one to return a
-		// ruby version string "wins"
-		versionEnvs := []VersionParserEnv{
-			{
+		// all version sources are looked up in the project path
+		projectContext := context
+		projectContext.WorkingDir = projectPath
+
+		versionEnvs := map[string]VersionParserEnv{
+			GemfileLockSource: {
 				Parser:  gemFileLockParser,
-				Path:    "Gemfile.lock",
-				Context: context,
+				Path:    GemfileLockSource,
+				Context: projectContext,
 				Logger:  logger,
 			},
-			{
+			GemfileSource: {
 				Parser:  gemFileParser,
-				Path:    "Gemfile",
-				Context: context,
+				Path:    GemfileSource,
+				Context: projectContext,
 				Logger:  logger,
 			},
-			{
+			RubyVersionSource: {
 				Parser:  rubyVersionParser,
-				Path:    ".ruby-version",
-				Context: context,
+				Path:    RubyVersionSource,
+				Context: projectContext,
 				Logger:  logger,
 			},
-			{
+			ToolVersionsSource: {
+				Parser:  toolVersionsParser,
+				Path:    ToolVersionsSource,
+				Context: projectContext,
+				Logger:  logger,
+			},
+			BuildpackYMLSource: {
 				Parser:  buildpackYMLParser,
-				Path:    "buildpack.yml",
-				Context: context,
+				Path:    BuildpackYMLSource,
+				Context: projectContext,
 				Logger:  logger,
 			},
 		}
 
-		for _, env := range versionEnvs {
-			err = ParseVersion(env, &rubyVersion)
+		rubyVersion := configuration.DefaultRubyVersion
+		versionSource := DefaultVersionSource
+
+		var candidates []VersionCandidate
+		for _, source := range versionSources {
+			if source == EnvironmentSource {
+				if version := os.Getenv(VersionEnv); version != "" {
+					logger.Detail("Found Ruby version in %s: %s", VersionEnv, version)
+					candidates = append(candidates, VersionCandidate{Source: source, Version: version})
+				}
+				continue
+			}
+			env := versionEnvs[source]
+
+			var version, requirement string
+			err = ParseRequirement(env, &version, &requirement)
 			if err != nil && !os.IsNotExist(err) {
 				logger.Detail("Parsing '%s' failed", env.Path)
 				return packit.DetectResult{}, err
 			}
+			if version != "" {
+				candidates = append(candidates, VersionCandidate{Source: source, Version: version, Requirement: requirement})
+			}
+		}
+
+		// apps without a Gemfile are only supported if they specify a Ruby
+		// version in one of the other version sources
+		if len(candidates) == 0 && !fileExists(filepath.Join(projectPath, GemfileSource)) && !fileExists(filepath.Join(projectPath, GemfileLockSource)) {
+			return packit.DetectResult{}, packit.Fail.WithMessage("no Gemfile and no Ruby version found in any of the version sources: %s", strings.Join(versionSources, ", "))
+		}
+
+		// the version sources are sorted by precedence, so the first candidate
+		// "wins"
+		if len(candidates) > 0 {
+			rubyVersion = candidates[0].Version
+			versionSource = candidates[0].Source
+		}
+
+		if len(candidates) > 1 {
+			logger.VersionCandidates(candidates)
+
+			conflictMode, err := VersionConflictMode()
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			conflicts := FindVersionConflicts(candidates)
+			if len(conflicts) > 0 && conflictMode != VersionConflictIgnore {
+				for _, conflict := range conflicts {
+					logger.Detail("Conflicting Ruby versions: %s", conflict)
+				}
+				if conflictMode == VersionConflictFail {
+					return packit.DetectResult{}, packit.Fail.WithMessage("conflicting Ruby versions: %s; align the version sources or set %s=%s to use the Ruby version with the highest precedence", conflicts[0], VersionConflictEnv, VersionConflictWarn)
+				}
+			}
+		}
+		logger.Detail("Detected Ruby version: %s (from %s)", rubyVersion, versionSource)
+
+		build, launch, err := LayerFlags()
+		if err != nil {
+			return packit.DetectResult{}, err
 		}
-		logger.Detail("Detected Ruby version: %s", rubyVersion)
 
 		requirements := []packit.BuildPlanRequirement{
 			{
 				Name: "rvm",
 				Metadata: BuildPlanMetadata{
 					RubyVersion:   rubyVersion,
-					VersionSource: "rvm-cnb",
+					VersionSource: versionSource,
+					Build:         build,
+					Launch:        launch,
 				},
 			},
 		}
 
-		buildPackYMLPath := filepath.Join(context.WorkingDir, "buildpack.yml")
+		buildPackYMLPath := filepath.Join(projectPath, BuildpackYMLSource)
 		buildPackYML, err := BuildpackYMLParse(buildPackYMLPath)
 		if err != nil {
 			logger.Detail("Parsing '%s' failed", buildPackYMLPath)
 			return packit.DetectResult{}, err
 		}
 
-		if buildPackYML.RequireNode {
-			logger.Detail("The buildpack 'node' was requested as a requirement")
-			nodeVersion := configuration.DefaultNodeVersion
-			if buildPackYML.NodeVersion != "" {
-				nodeVersion = buildPackYML.NodeVersion
+		extraRequirements, err := ExtraRequirements(buildPackYML, configuration)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+		for _, requirement := range extraRequirements {
+			logger.Detail("The buildpack '%s' was requested as a requirement", requirement.Name)
+			if metadata := requirement.Metadata.(RequirementMetadata); metadata.Version != "" {
+				logger.Detail("The buildpack requested %s version: %s", requirement.Name, metadata.Version)
 			}
-			logger.Detail("The buildpack requested node version: %s", nodeVersion)
-			requirements = append(requirements, packit.BuildPlanRequirement{
-				Name: "node",
-				Metadata: BuildPlanMetadata{
-					RubyVersion:   nodeVersion,
-					VersionSource: "rvm-cnb",
-				},
-			})
 		}
+		requirements = append(requirements, extraRequirements...)
 
 		return packit.DetectResult{
 			Plan: packit.BuildPlan{
@@ -136,3 +234,8 @@ func Detect(logger LogEmitter, rubyVersionParser VersionParser, gemFileParser Ve
 		}, nil
 	}
 }
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -27,45 +27,164 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		gemFileParser      *fakes.VersionParser
 		gemFileLockParser  *fakes.VersionParser
 		buildpackYMLParser *fakes.VersionParser
+		toolVersionsParser *fakes.VersionParser
 		detect             packit.DetectFunc
 	)
 
 	it.Before(func() {
+		var err error
+		layersDir, err = ioutil.TempDir("", "layers")
+		Expect(err).NotTo(HaveOccurred())
+
+		cnbDir, err = ioutil.TempDir("", "cnb")
+		Expect(err).NotTo(HaveOccurred())
+
+		someBuildPackTomlFile, err := ioutil.ReadFile("../test/fixtures/before/some_buildpack.toml")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), someBuildPackTomlFile, 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		workingDir, err = ioutil.TempDir("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
 		rubyVersionParser = &fakes.VersionParser{}
 		gemFileParser = &fakes.VersionParser{}
 		gemFileLockParser = &fakes.VersionParser{}
 		buildpackYMLParser = &fakes.VersionParser{}
+		toolVersionsParser = &fakes.VersionParser{}
 
 		logEmitter := rvm.NewLogEmitter(os.Stdout)
-		detect = rvm.Detect(logEmitter, rubyVersionParser, gemFileParser, gemFileLockParser, buildpackYMLParser)
+		detect = rvm.Detect(logEmitter, rubyVersionParser, gemFileParser, gemFileLockParser, buildpackYMLParser, toolVersionsParser)
 	})
 
-	it("returns a plan that does not provide rvm because no Gemfile was found", func() {
-		result, err := detect(packit.DetectContext{
-			WorkingDir: "/working-dir",
-		})
-		Expect(err).To(HaveOccurred())
-		Expect(result.Plan).To(Equal(packit.BuildPlan{Provides: nil, Requires: nil, Or: nil}))
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+		Expect(os.RemoveAll(cnbDir)).To(Succeed())
+		Expect(os.RemoveAll(layersDir)).To(Succeed())
 	})
 
-	context("when the app presents a Gemfile", func() {
-		it.Before(func() {
-			var err error
-			layersDir, err = ioutil.TempDir("", "layers")
+	context("when the app does not present a Gemfile", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("BP_RVM_VERSION")).To(Succeed())
+		})
+
+		it("fails detection because no version source was found", func() {
+			result, err := detect(packit.DetectContext{
+				CNBPath:    cnbDir,
+				WorkingDir: workingDir,
+			})
+			Expect(err).To(MatchError(packit.Fail.WithMessage("no Gemfile and no Ruby version found in any of the version sources: BP_RVM_VERSION, buildpack.yml, .ruby-version, .tool-versions, Gemfile, Gemfile.lock")))
+			Expect(result.Plan).To(Equal(packit.BuildPlan{Provides: nil, Requires: nil, Or: nil}))
+		})
+
+		it("returns a plan that requires rvm when .tool-versions specifies a Ruby version", func() {
+			toolVersionsParser.ParseVersionCall.Returns.Version = "3.1.2"
+
+			result, err := detect(packit.DetectContext{
+				CNBPath:    cnbDir,
+				WorkingDir: workingDir,
+			})
 			Expect(err).NotTo(HaveOccurred())
+			Expect(toolVersionsParser.ParseVersionCall.Receives.Path).To(Equal(filepath.Join(workingDir, ".tool-versions")))
+			Expect(result.Plan.Requires[0].Metadata).To(Equal(rvm.BuildPlanMetadata{
+				RubyVersion:   "3.1.2",
+				VersionSource: ".tool-versions",
+				Build:         true,
+				Launch:        true,
+			}))
+		})
+
+		it("returns a plan that requires rvm when BP_RVM_VERSION is set", func() {
+			Expect(os.Setenv("BP_RVM_VERSION", "3.2.0")).To(Succeed())
+			rubyVersionParser.ParseVersionCall.Returns.Version = "3.2"
 
-			cnbDir, err = ioutil.TempDir("", "cnb")
+			result, err := detect(packit.DetectContext{
+				CNBPath:    cnbDir,
+				WorkingDir: workingDir,
+			})
 			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires[0].Metadata).To(Equal(rvm.BuildPlanMetadata{
+				RubyVersion:   "3.2.0",
+				VersionSource: "BP_RVM_VERSION",
+				Build:         true,
+				Launch:        true,
+			}))
+		})
+	})
 
-			someBuildPackTomlFile, err := ioutil.ReadFile("../test/fixtures/before/some_buildpack.toml")
+	context("when the platform sets the target instead of a stack ID", func() {
+		it.Before(func() {
+			buildpackTOML, err := ioutil.ReadFile("../test/fixtures/before/some_buildpack.toml")
 			Expect(err).NotTo(HaveOccurred())
+			buildpackTOML = append(buildpackTOML, []byte(`
+    [[metadata.configuration.targets]]
+      stacks = ["io.buildpacks.stacks.jammy"]
+      distro = "ubuntu"
+      distro_version = "22.04"
+      default_ruby_version = "3.2.2"
+`)...)
+			Expect(ioutil.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), buildpackTOML, 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte{}, 0644)).To(Succeed())
+
+			Expect(os.Setenv("CNB_TARGET_OS", "linux")).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_ARCH", "arm64")).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_DISTRO_NAME", "ubuntu")).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_DISTRO_VERSION", "22.04")).To(Succeed())
+		})
+
+		it.After(func() {
+			for _, name := range []string{"CNB_TARGET_OS", "CNB_TARGET_ARCH", "CNB_TARGET_DISTRO_NAME", "CNB_TARGET_DISTRO_VERSION"} {
+				Expect(os.Unsetenv(name)).To(Succeed())
+			}
+		})
 
-			err = ioutil.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), someBuildPackTomlFile, 0644)
+		it("selects the default Ruby version of the target", func() {
+			result, err := detect(packit.DetectContext{
+				CNBPath:    cnbDir,
+				WorkingDir: workingDir,
+			})
 			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires[0].Metadata).To(Equal(rvm.BuildPlanMetadata{
+				RubyVersion:   "3.2.2",
+				VersionSource: "rvm-cnb",
+				Build:         true,
+				Launch:        true,
+			}))
+		})
+	})
 
-			workingDir, err = ioutil.TempDir("", "working-dir")
+	context("when the app is located in a project path", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "services", "api"), os.ModePerm)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(workingDir, "services", "api", "Gemfile"), []byte{}, 0644)).To(Succeed())
+			Expect(os.Setenv("BP_RVM_PROJECT_PATH", "services/api")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_RVM_PROJECT_PATH")).To(Succeed())
+		})
+
+		it("looks up the version sources in the project path", func() {
+			gemFileParser.ParseVersionCall.Returns.Version = "3.1.2"
+
+			result, err := detect(packit.DetectContext{
+				CNBPath:    cnbDir,
+				WorkingDir: workingDir,
+			})
 			Expect(err).NotTo(HaveOccurred())
+			Expect(gemFileParser.ParseVersionCall.Receives.Path).To(Equal(filepath.Join(workingDir, "services", "api", "Gemfile")))
+			Expect(result.Plan.Requires[0].Metadata).To(Equal(rvm.BuildPlanMetadata{
+				RubyVersion:   "3.1.2",
+				VersionSource: "Gemfile",
+				Build:         true,
+				Launch:        true,
+			}))
+		})
+	})
 
+	context("when the app presents a Gemfile", func() {
+		it.Before(func() {
 			basicGemfile, err := ioutil.ReadFile("../test/fixtures/before/Gemfile")
 			Expect(err).NotTo(HaveOccurred())
 
@@ -89,6 +208,8 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 						Metadata: rvm.BuildPlanMetadata{
 							RubyVersion:   "2.7.1",
 							VersionSource: "rvm-cnb",
+							Build:         true,
+							Launch:        true,
 						},
 					},
 				},
@@ -100,7 +221,6 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 			err := ioutil.WriteFile(rubyVersionPath, []byte("2.3.8\n"), 0644)
 			Expect(err).NotTo(HaveOccurred())
 
-			rubyVersionParser.ParseVersionCall.Receives.Path = rubyVersionPath
 			rubyVersionParser.ParseVersionCall.Returns.Version = "2.3.8"
 
 			result, err := detect(packit.DetectContext{
@@ -117,7 +237,9 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 						Name: "rvm",
 						Metadata: rvm.BuildPlanMetadata{
 							RubyVersion:   "2.3.8",
-							VersionSource: "rvm-cnb",
+							VersionSource: ".ruby-version",
+							Build:         true,
+							Launch:        true,
 						},
 					},
 				},
@@ -132,8 +254,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 			err = ioutil.WriteFile(gemFilePath, rubyVersionGemfile, 0644)
 			Expect(err).NotTo(HaveOccurred())
 
-			rubyVersionParser.ParseVersionCall.Receives.Path = gemFilePath
-			rubyVersionParser.ParseVersionCall.Returns.Version = "2.5.3"
+			gemFileParser.ParseVersionCall.Returns.Version = "2.5.3"
 
 			result, err := detect(packit.DetectContext{
 				CNBPath:    cnbDir,
@@ -149,7 +270,9 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 						Name: "rvm",
 						Metadata: rvm.BuildPlanMetadata{
 							RubyVersion:   "2.5.3",
-							VersionSource: "rvm-cnb",
+							VersionSource: "Gemfile",
+							Build:         true,
+							Launch:        true,
 						},
 					},
 				},
@@ -164,8 +287,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 			err = ioutil.WriteFile(gemFileLockPath, rubyVersionGemfileLock, 0644)
 			Expect(err).NotTo(HaveOccurred())
 
-			rubyVersionParser.ParseVersionCall.Receives.Path = gemFileLockPath
-			rubyVersionParser.ParseVersionCall.Returns.Version = "2.5.3"
+			gemFileLockParser.ParseVersionCall.Returns.Version = "2.5.3"
 
 			result, err := detect(packit.DetectContext{
 				CNBPath:    cnbDir,
@@ -181,7 +303,9 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 						Name: "rvm",
 						Metadata: rvm.BuildPlanMetadata{
 							RubyVersion:   "2.5.3",
-							VersionSource: "rvm-cnb",
+							VersionSource: "Gemfile.lock",
+							Build:         true,
+							Launch:        true,
 						},
 					},
 				},
@@ -196,8 +320,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 			err = ioutil.WriteFile(buildPackYMLPath, buildPackYML, 0644)
 			Expect(err).NotTo(HaveOccurred())
 
-			rubyVersionParser.ParseVersionCall.Receives.Path = buildPackYMLPath
-			rubyVersionParser.ParseVersionCall.Returns.Version = "2.5.3"
+			buildpackYMLParser.ParseVersionCall.Returns.Version = "2.5.3"
 
 			result, err := detect(packit.DetectContext{
 				CNBPath:    cnbDir,
@@ -213,7 +336,9 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 						Name: "rvm",
 						Metadata: rvm.BuildPlanMetadata{
 							RubyVersion:   "2.5.3",
-							VersionSource: "rvm-cnb",
+							VersionSource: "buildpack.yml",
+							Build:         true,
+							Launch:        true,
 						},
 					},
 				},
@@ -228,8 +353,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 			err = ioutil.WriteFile(buildPackYMLPath, buildPackYML, 0644)
 			Expect(err).NotTo(HaveOccurred())
 
-			rubyVersionParser.ParseVersionCall.Receives.Path = buildPackYMLPath
-			rubyVersionParser.ParseVersionCall.Returns.Version = "2.5.3"
+			buildpackYMLParser.ParseVersionCall.Returns.Version = "2.5.3"
 
 			buildPackYMLParsed, err := rvm.BuildpackYMLParse(buildPackYMLPath)
 			Expect(err).NotTo(HaveOccurred())
@@ -248,24 +372,206 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 						Name: "rvm",
 						Metadata: rvm.BuildPlanMetadata{
 							RubyVersion:   "2.5.3",
-							VersionSource: "rvm-cnb",
+							VersionSource: "buildpack.yml",
+							Build:         true,
+							Launch:        true,
 						},
 					},
 					{
 						Name: "node",
-						Metadata: rvm.BuildPlanMetadata{
-							RubyVersion:   buildPackYMLParsed.NodeVersion,
-							VersionSource: "rvm-cnb",
+						Metadata: rvm.RequirementMetadata{
+							Version:       buildPackYMLParsed.NodeVersion,
+							VersionSource: "buildpack.yml",
+							Build:         true,
+							Launch:        true,
 						},
 					},
 				},
 			}))
 		})
 
-		it.After(func() {
-			Expect(os.RemoveAll(workingDir)).To(Succeed())
-			Expect(os.RemoveAll(cnbDir)).To(Succeed())
-			Expect(os.RemoveAll(layersDir)).To(Succeed())
+		context("when the precedence of the version sources is configured", func() {
+			it.Before(func() {
+				gemFileLockParser.ParseVersionCall.Returns.Version = "2.7.1"
+				rubyVersionParser.ParseVersionCall.Returns.Version = "2.7"
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RVM_VERSION_SOURCES")).To(Succeed())
+			})
+
+			it("reports the source with the highest precedence", func() {
+				Expect(os.Setenv("BP_RVM_VERSION_SOURCES", "Gemfile.lock,.ruby-version")).To(Succeed())
+
+				result, err := detect(packit.DetectContext{
+					CNBPath:    cnbDir,
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires[0].Metadata).To(Equal(rvm.BuildPlanMetadata{
+					RubyVersion:   "2.7.1",
+					VersionSource: "Gemfile.lock",
+					Build:         true,
+					Launch:        true,
+				}))
+			})
+
+			it("does not consult disabled sources", func() {
+				Expect(os.Setenv("BP_RVM_VERSION_SOURCES", "Gemfile")).To(Succeed())
+
+				result, err := detect(packit.DetectContext{
+					CNBPath:    cnbDir,
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(gemFileLockParser.ParseVersionCall.CallCount).To(Equal(0))
+				Expect(rubyVersionParser.ParseVersionCall.CallCount).To(Equal(0))
+				Expect(result.Plan.Requires[0].Metadata).To(Equal(rvm.BuildPlanMetadata{
+					RubyVersion:   "2.7.1",
+					VersionSource: "rvm-cnb",
+					Build:         true,
+					Launch:        true,
+				}))
+			})
+
+			it("uses BP_RVM_VERSION even if the order does not list it", func() {
+				Expect(os.Setenv("BP_RVM_VERSION_SOURCES", "Gemfile.lock,.ruby-version")).To(Succeed())
+				Expect(os.Setenv("BP_RVM_VERSION", "2.7.1")).To(Succeed())
+				defer os.Unsetenv("BP_RVM_VERSION")
+
+				result, err := detect(packit.DetectContext{
+					CNBPath:    cnbDir,
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires[0].Metadata).To(Equal(rvm.BuildPlanMetadata{
+					RubyVersion:   "2.7.1",
+					VersionSource: "BP_RVM_VERSION",
+					Build:         true,
+					Launch:        true,
+				}))
+			})
+
+			it("returns an error for an unknown source", func() {
+				Expect(os.Setenv("BP_RVM_VERSION_SOURCES", "Rakefile")).To(Succeed())
+
+				_, err := detect(packit.DetectContext{
+					CNBPath:    cnbDir,
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("unknown Ruby version source 'Rakefile'")))
+			})
+		})
+
+		context("when Ruby is not required at launch", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_RVM_LAUNCH", "false")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RVM_LAUNCH")).To(Succeed())
+			})
+
+			it("returns a plan that requires rvm only during build", func() {
+				result, err := detect(packit.DetectContext{
+					CNBPath:    cnbDir,
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires[0].Metadata).To(Equal(rvm.BuildPlanMetadata{
+					RubyVersion:   "2.7.1",
+					VersionSource: "rvm-cnb",
+					Build:         true,
+					Launch:        false,
+				}))
+			})
+		})
+
+		context("when the version sources request conflicting Ruby versions", func() {
+			it.Before(func() {
+				gemFileLockParser.ParseVersionCall.Returns.Version = "2.7.0p0"
+				rubyVersionParser.ParseVersionCall.Returns.Version = "3.1.2"
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RVM_VERSION_CONFLICT")).To(Succeed())
+			})
+
+			it("fails by default", func() {
+				_, err := detect(packit.DetectContext{
+					CNBPath:    cnbDir,
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(packit.Fail.WithMessage("conflicting Ruby versions: .ruby-version requests Ruby 3.1.2 but Gemfile.lock requests Ruby 2.7.0p0; align the version sources or set BP_RVM_VERSION_CONFLICT=warn to use the Ruby version with the highest precedence")))
+			})
+
+			it("uses the version with the highest precedence when conflicts only produce a warning", func() {
+				Expect(os.Setenv("BP_RVM_VERSION_CONFLICT", "warn")).To(Succeed())
+
+				result, err := detect(packit.DetectContext{
+					CNBPath:    cnbDir,
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires[0].Metadata).To(Equal(rvm.BuildPlanMetadata{
+					RubyVersion:   "3.1.2",
+					VersionSource: ".ruby-version",
+					Build:         true,
+					Launch:        true,
+				}))
+			})
+		})
+
+		context("when the Gemfile requests a range of Ruby versions", func() {
+			it.Before(func() {
+				logEmitter := rvm.NewLogEmitter(os.Stdout)
+				detect = rvm.Detect(logEmitter, rubyVersionParser, rvm.NewGemfileParser(logEmitter), gemFileLockParser, buildpackYMLParser, toolVersionsParser)
+			})
+
+			it("accepts a version from another source that satisfies the requirement", func() {
+				Expect(ioutil.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte(`ruby ">= 2.7"`), 0644)).To(Succeed())
+				rubyVersionParser.ParseVersionCall.Returns.Version = "3.1.2"
+
+				result, err := detect(packit.DetectContext{
+					CNBPath:    cnbDir,
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires[0].Metadata).To(Equal(rvm.BuildPlanMetadata{
+					RubyVersion:   "3.1.2",
+					VersionSource: ".ruby-version",
+					Build:         true,
+					Launch:        true,
+				}))
+			})
+
+			it("fails for a version from another source that does not satisfy the requirement", func() {
+				Expect(ioutil.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte(`ruby "~> 3.1"`), 0644)).To(Succeed())
+				rubyVersionParser.ParseVersionCall.Returns.Version = "2.7.6"
+
+				_, err := detect(packit.DetectContext{
+					CNBPath:    cnbDir,
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("conflicting Ruby versions: .ruby-version requests Ruby 2.7.6 but Gemfile requests Ruby ~> 3.1")))
+			})
+		})
+
+		context("when the Gemfile reads the Ruby version from a missing file", func() {
+			it.Before(func() {
+				logEmitter := rvm.NewLogEmitter(os.Stdout)
+				detect = rvm.Detect(logEmitter, rubyVersionParser, rvm.NewGemfileParser(logEmitter), gemFileLockParser, buildpackYMLParser, toolVersionsParser)
+			})
+
+			it("fails instead of falling back to the default Ruby version", func() {
+				Expect(ioutil.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte(`ruby file: ".ruby-version"`), 0644)).To(Succeed())
+
+				_, err := detect(packit.DetectContext{
+					CNBPath:    cnbDir,
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("reading the file '.ruby-version' referenced by the Gemfile failed")))
+			})
 		})
 	})
 }
@@ -0,0 +1,73 @@
+package rvm
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/paketo-buildpacks/packit/v2/vacation"
+)
+
+// Download downloads the file at the given URI to the given path
+func Download(uri, path string) error {
+	response, err := http.Get(uri)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", uri, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", uri, response.Status)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", uri, err)
+	}
+
+	return file.Close()
+}
+
+// Extract extracts a possibly compressed tar archive into the given
+// directory, removing the given number of leading path components
+func Extract(path, destination string, stripComponents int) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = vacation.NewArchive(file).StripComponents(stripComponents).Decompress(destination)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// downloadAndExtract downloads an archive into a temporary file and extracts
+// it into the given directory, removing the top-level directory of the
+// archive
+func downloadAndExtract(uri, destination string) error {
+	file, err := ioutil.TempFile("", "archive")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	err = Download(uri, file.Name())
+	if err != nil {
+		return err
+	}
+
+	return Extract(file.Name(), destination, 1)
+}
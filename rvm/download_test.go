@@ -0,0 +1,98 @@
+package rvm_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDownload(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workDir string
+		server  *httptest.Server
+		archive []byte
+	)
+
+	it.Before(func() {
+		var err error
+		workDir, err = ioutil.TempDir("", "download")
+		Expect(err).NotTo(HaveOccurred())
+
+		buffer := bytes.NewBuffer(nil)
+		gzipWriter := gzip.NewWriter(buffer)
+		tarWriter := tar.NewWriter(gzipWriter)
+		for name, content := range map[string]string{
+			"rvm-1.29.12/install":     "#!/usr/bin/env bash",
+			"rvm-1.29.12/scripts/rvm": "rvm",
+		} {
+			Expect(tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content))})).To(Succeed())
+			_, err = tarWriter.Write([]byte(content))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(tarWriter.Close()).To(Succeed())
+		Expect(gzipWriter.Close()).To(Succeed())
+		archive = buffer.Bytes()
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/1.29.12.tar.gz" {
+				http.NotFound(w, req)
+				return
+			}
+			_, _ = w.Write(archive)
+		}))
+	})
+
+	it.After(func() {
+		server.Close()
+		Expect(os.RemoveAll(workDir)).To(Succeed())
+	})
+
+	it("downloads a file", func() {
+		path := filepath.Join(workDir, "rvm.tar.gz")
+		Expect(rvm.Download(server.URL+"/1.29.12.tar.gz", path)).To(Succeed())
+
+		content, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal(archive))
+	})
+
+	it("extracts an archive removing the top-level directory", func() {
+		path := filepath.Join(workDir, "rvm.tar.gz")
+		Expect(ioutil.WriteFile(path, archive, 0644)).To(Succeed())
+
+		Expect(rvm.Extract(path, filepath.Join(workDir, "rvm"), 1)).To(Succeed())
+
+		content, err := ioutil.ReadFile(filepath.Join(workDir, "rvm", "scripts", "rvm"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("rvm"))
+		Expect(filepath.Join(workDir, "rvm", "install")).To(BeAnExistingFile())
+	})
+
+	context("failure cases", func() {
+		it("returns an error if the server does not return the file", func() {
+			err := rvm.Download(server.URL+"/missing.tar.gz", filepath.Join(workDir, "missing.tar.gz"))
+			Expect(err).To(MatchError("failed to download " + server.URL + "/missing.tar.gz: 404 Not Found"))
+		})
+
+		it("returns an error if the file is not an archive", func() {
+			path := filepath.Join(workDir, "rvm.tar.gz")
+			Expect(ioutil.WriteFile(path, []byte("not an archive"), 0644)).To(Succeed())
+
+			err := rvm.Extract(path, filepath.Join(workDir, "rvm"), 1)
+			Expect(err).To(MatchError(ContainSubstring("failed to extract")))
+		})
+	})
+}
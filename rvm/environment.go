@@ -17,6 +17,23 @@ func DefaultVariables(rvmLayer *packit.Layer) []string {
 	}
 }
 
+// RubyLayerVariables returns the environment variables that make RVM install
+// Rubies and gems into the Ruby layer instead of the RVM layer
+func RubyLayerVariables(path string) []string {
+	return []string{
+		"rvm_rubies_path=" + filepath.Join(path, "rubies"),
+		"rvm_gems_path=" + filepath.Join(path, "gems"),
+	}
+}
+
+// ArchivesLayerVariables returns the environment variables that make RVM
+// download Ruby source archives into the archives layer
+func ArchivesLayerVariables(path string) []string {
+	return []string{
+		"rvm_archives_path=" + path,
+	}
+}
+
 // Environment represents a shell environment
 type Environment struct {
 	logger LogEmitter
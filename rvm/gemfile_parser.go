@@ -1,42 +1,596 @@
 package rvm
 
 import (
-	"bufio"
+	"fmt"
+	"io/ioutil"
 	"os"
-	"regexp"
+	"path/filepath"
+	"strings"
+	"unicode"
 )
 
-// RubyVersionRegEx is a regular expression used to fin the ruby version in a
-// call to the "ruby" method, see: https://bundler.io/man/gemfile.5.html#RUBY
-const RubyVersionRegEx = `^ruby ["']([[:alnum:]\.\-]+)["'].*$`
-
-// GemfileParser represents a Gemfile parser
-type GemfileParser struct{}
+// GemfileParser represents a Gemfile parser. Instead of matching single lines
+// it tokenizes the Gemfile so that the different forms of the "ruby" method
+// can be understood, see: https://bundler.io/man/gemfile.5.html#RUBY
+type GemfileParser struct {
+	logger LogEmitter
+}
 
 // NewGemfileParser creates a new Gemfile parser
-func NewGemfileParser() GemfileParser {
-	return GemfileParser{}
+func NewGemfileParser(logger LogEmitter) GemfileParser {
+	return GemfileParser{
+		logger: logger,
+	}
 }
 
 // ParseVersion looks for a Gemfile file in a given path and, if it
-// exists, parses it to find a ruby version spec
+// exists, parses it to find a ruby version spec. Declarations that can not be
+// evaluated statically, including those within an if, unless or case block,
+// are reported as a warning and result in an empty version.
 func (r GemfileParser) ParseVersion(path string) (string, error) {
-	gemfile, err := os.Open(path)
+	version, _, err := r.ParseRequirement(path)
+	return version, err
+}
+
+// ParseRequirement works like ParseVersion but additionally returns the
+// requirement the version was derived from, e.g. ">= 2.7", or an empty
+// string if the Gemfile requests a single version
+func (r GemfileParser) ParseRequirement(path string) (string, string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	// blocks holds for each open block whether it is conditional
+	var blocks []bool
+	for _, statement := range gemfileStatements(tokenizeGemfile(string(contents))) {
+		args, ok := rubyDeclarationArgs(statement)
+		if !ok {
+			blocks = gemfileBlocks(blocks, statement)
+			continue
+		}
+
+		if gemfileConditional(blocks, statement) {
+			r.logger.Detail("Warning: unsupported dynamic Ruby version declaration in Gemfile: ruby %s within a conditional block", gemfileExpressionString(args))
+			return "", "", nil
+		}
+
+		declaration := gemfileRubyDeclaration{
+			dir:    filepath.Dir(path),
+			logger: r.logger,
+		}
+		return declaration.version(args)
+	}
+
+	return "", "", nil
+}
+
+// gemfileBlocks returns the blocks open after the given statement, which
+// either opens a block, closes the innermost one or leaves them unchanged
+func gemfileBlocks(blocks []bool, statement []gemfileToken) []bool {
+	first, last := statement[0], statement[len(statement)-1]
+	if first.kind != gemfileIdentifier {
+		first = gemfileToken{}
+	}
+	if last.kind != gemfileIdentifier {
+		last = gemfileToken{}
+	}
+
+	switch {
+	case first.value == "end":
+		if len(blocks) > 0 {
+			blocks = blocks[:len(blocks)-1]
+		}
+	case last.value == "end":
+		// a block opened and closed within the statement
+	case first.value == "if", first.value == "unless", first.value == "case":
+		blocks = append(blocks, true)
+	case first.value == "while", first.value == "until", first.value == "begin",
+		first.value == "def", first.value == "class", first.value == "module":
+		blocks = append(blocks, false)
+	default:
+		for _, token := range statement {
+			if token.kind == gemfileIdentifier && token.value == "do" {
+				blocks = append(blocks, false)
+				break
+			}
+		}
+	}
+	return blocks
+}
+
+// gemfileConditional returns true if the given statement is executed
+// conditionally, because it is within an if, unless or case block or starts
+// one itself, e.g. `if ENV["CI"] then ruby "3.1.2" end`
+func gemfileConditional(blocks []bool, statement []gemfileToken) bool {
+	for _, conditional := range blocks {
+		if conditional {
+			return true
+		}
+	}
+	first := statement[0]
+	return first.kind == gemfileIdentifier && (first.value == "if" || first.value == "unless" || first.value == "case")
+}
+
+type gemfileTokenKind int
+
+const (
+	gemfileIdentifier gemfileTokenKind = iota
+	gemfileString
+	gemfileInterpolatedString
+	gemfileSymbol
+	gemfileLabel
+	gemfileNumber
+	gemfilePunctuation
+	gemfileNewline
+)
+
+type gemfileToken struct {
+	kind  gemfileTokenKind
+	value string
+}
+
+// tokenizeGemfile splits the contents of a Gemfile into tokens. Comments are
+// dropped and line breaks are only kept where they terminate a statement,
+// i.e. not within brackets or after a trailing comma.
+func tokenizeGemfile(contents string) []gemfileToken {
+	var (
+		tokens []gemfileToken
+		depth  int
+		runes  = []rune(contents)
+	)
+
+	continues := func() bool {
+		if depth > 0 || len(tokens) == 0 {
+			return true
+		}
+		last := tokens[len(tokens)-1]
+		switch last.kind {
+		case gemfileNewline, gemfileLabel:
+			return true
+		case gemfilePunctuation:
+			return last.value == "," || last.value == "=>" || last.value == "."
+		}
+		return false
+	}
+
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		lineStart := i == 0 || runes[i-1] == '\n'
+
+		switch {
+		case lineStart && strings.HasPrefix(string(runes[i:]), "=begin"):
+			end := strings.Index(string(runes[i:]), "\n=end")
+			if end < 0 {
+				return tokens
+			}
+			i += end + len("\n=end")
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+
+		case c == '\\' && i+1 < len(runes) && runes[i+1] == '\n':
+			i += 2
+
+		case c == '\n' || c == ';':
+			if !continues() {
+				tokens = append(tokens, gemfileToken{kind: gemfileNewline})
+			}
+			i++
+
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+
+		case c == '"' || c == '\'':
+			var (
+				value        strings.Builder
+				interpolated bool
+			)
+			i++
+			for i < len(runes) && runes[i] != c {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				} else if c == '"' && runes[i] == '#' && i+1 < len(runes) && runes[i+1] == '{' {
+					interpolated = true
+				}
+				value.WriteRune(runes[i])
+				i++
+			}
+			i++
+
+			kind := gemfileString
+			if interpolated {
+				kind = gemfileInterpolatedString
+			}
+			tokens = append(tokens, gemfileToken{kind: kind, value: value.String()})
+
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			if i < len(runes) && (runes[i] == '?' || runes[i] == '!') {
+				i++
+			}
+
+			value := string(runes[start:i])
+			if i < len(runes) && runes[i] == ':' && (i+1 >= len(runes) || runes[i+1] != ':') {
+				tokens = append(tokens, gemfileToken{kind: gemfileLabel, value: value})
+				i++
+				continue
+			}
+			tokens = append(tokens, gemfileToken{kind: gemfileIdentifier, value: value})
+
+		case c == ':' && i+1 < len(runes) && (runes[i+1] == '_' || unicode.IsLetter(runes[i+1])):
+			start := i + 1
+			i++
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, gemfileToken{kind: gemfileSymbol, value: string(runes[start:i])})
+
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, gemfileToken{kind: gemfileNumber, value: string(runes[start:i])})
+
+		default:
+			value := string(c)
+			if i+1 < len(runes) {
+				switch pair := string(runes[i : i+2]); pair {
+				case "=>", "::", "==", "!=", "||", "&&":
+					value = pair
+				}
+			}
+			i += len([]rune(value))
+
+			switch value {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				if depth > 0 {
+					depth--
+				}
+			}
+			tokens = append(tokens, gemfileToken{kind: gemfilePunctuation, value: value})
+		}
+	}
+
+	return tokens
+}
+
+// gemfileStatements groups tokens into statements
+func gemfileStatements(tokens []gemfileToken) [][]gemfileToken {
+	var (
+		statements [][]gemfileToken
+		current    []gemfileToken
+	)
+
+	for _, token := range tokens {
+		if token.kind == gemfileNewline {
+			if len(current) > 0 {
+				statements = append(statements, current)
+			}
+			current = nil
+			continue
+		}
+		current = append(current, token)
+	}
+	if len(current) > 0 {
+		statements = append(statements, current)
+	}
+
+	return statements
+}
+
+// rubyDeclarationArgs returns the arguments of a call to the "ruby" method if
+// the given statement is one. Trailing conditional modifiers like
+// `ruby "3.1.2" if ENV["CI"]` are dropped.
+func rubyDeclarationArgs(statement []gemfileToken) ([][]gemfileToken, bool) {
+	start := -1
+	for i, token := range statement {
+		if token.kind != gemfileIdentifier || token.value != "ruby" {
+			continue
+		}
+		if i > 0 {
+			previous := statement[i-1]
+			if previous.kind != gemfileIdentifier || (previous.value != "then" && previous.value != "else") {
+				continue
+			}
+		}
+		start = i + 1
+		break
+	}
+	if start < 0 || start >= len(statement) {
+		return nil, false
+	}
+
+	// anything but an opening bracket right after "ruby" means the identifier
+	// is not a method call, e.g. an assignment or a comparison
+	rest := statement[start:]
+	if rest[0].kind == gemfilePunctuation && rest[0].value != "(" {
+		return nil, false
+	}
+
+	parenthesized := rest[0].kind == gemfilePunctuation && rest[0].value == "("
+	if parenthesized {
+		rest = rest[1:]
+	}
+
+	var (
+		args    [][]gemfileToken
+		current []gemfileToken
+		depth   int
+	)
+tokens:
+	for _, token := range rest {
+		switch {
+		case token.kind == gemfilePunctuation && (token.value == "(" || token.value == "[" || token.value == "{"):
+			depth++
+		case token.kind == gemfilePunctuation && (token.value == ")" || token.value == "]" || token.value == "}"):
+			depth--
+			if depth < 0 {
+				break tokens
+			}
+		case depth == 0 && token.kind == gemfilePunctuation && token.value == ",":
+			args = append(args, current)
+			current = nil
+			continue
+		case depth == 0 && !parenthesized && token.kind == gemfileIdentifier:
+			switch token.value {
+			case "if", "unless", "end":
+				break tokens
+			}
+		}
+		current = append(current, token)
+	}
+	if len(current) > 0 {
+		args = append(args, current)
+	}
+
+	return args, len(args) > 0
+}
+
+type gemfileRubyDeclaration struct {
+	dir    string
+	logger LogEmitter
+}
+
+// version evaluates the arguments of a "ruby" declaration and returns a Ruby
+// version string that can be installed by RVM, along with the requirement
+// it was derived from unless the declaration requests a single MRI version
+func (d gemfileRubyDeclaration) version(args [][]gemfileToken) (string, string, error) {
+	var (
+		requirements []string
+		options      = map[string]string{}
+	)
+
+	for _, arg := range args {
+		name, expression := gemfileKeywordArg(arg)
+
+		value, ok, err := d.evaluate(expression)
+		if err != nil {
+			return "", "", err
+		}
+		if !ok {
+			d.logger.Detail("Warning: unsupported dynamic Ruby version declaration in Gemfile: ruby %s", gemfileExpressionString(args))
+			return "", "", nil
+		}
+
+		if name == "" {
+			requirements = append(requirements, value)
+			continue
+		}
+		options[name] = value
+	}
+
+	if path, ok := options["file"]; ok {
+		contents, err := d.readFile(path)
+		if err != nil {
+			return "", "", err
+		}
+		requirements = append(requirements, contents)
+	}
+
+	version := RubyVersionFromRequirements(requirements)
+	if version == "" && len(requirements) > 0 {
+		d.logger.Detail("Warning: unable to derive an installable Ruby version from Gemfile requirements '%s'", strings.Join(requirements, ", "))
+		return "", "", nil
+	}
+
+	if patchlevel, ok := options["patchlevel"]; ok && version != "" {
+		version = version + "-p" + strings.TrimPrefix(patchlevel, "p")
+	}
+
+	if engine, ok := options["engine"]; ok && engine != "ruby" {
+		version = engine
+		if engineVersion, ok := options["engine_version"]; ok {
+			version = engine + "-" + RubyVersionFromRequirements([]string{engineVersion})
+		}
+		return version, "", nil
+	}
+
+	var requirement string
+	for _, part := range strings.Split(strings.Join(requirements, ","), ",") {
+		if operator, _ := splitRequirement(part); operator != "" && operator != "=" {
+			requirement = strings.Join(requirements, ", ")
+		}
+	}
+
+	return version, requirement, nil
+}
+
+// evaluate returns the value of an expression used as an argument to the
+// "ruby" method. Besides string literals it understands lookups of
+// environment variables and reading of files in the application directory.
+func (d gemfileRubyDeclaration) evaluate(expression []gemfileToken) (string, bool, error) {
+	matches := func(values ...string) bool {
+		if len(expression) < len(values) {
+			return false
+		}
+		for i, value := range values {
+			if value != "" && expression[i].value != value {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch {
+	case len(expression) == 1 && expression[0].kind == gemfileString:
+		return expression[0].value, true, nil
+
+	case len(expression) == 1 && expression[0].kind == gemfileNumber:
+		return expression[0].value, true, nil
+
+	case len(expression) == 4 && matches("ENV", "[", "", "]") && expression[2].kind == gemfileString:
+		value, ok := os.LookupEnv(expression[2].value)
+		return value, ok, nil
+
+	case matches("ENV", ".", "fetch", "("):
+		args := gemfileCallArgs(expression[4:])
+		if len(args) == 0 || args[0].kind != gemfileString {
+			return "", false, nil
+		}
+		if value, ok := os.LookupEnv(args[0].value); ok {
+			return value, true, nil
+		}
+		if len(args) > 1 && args[1].kind == gemfileString {
+			return args[1].value, true, nil
+		}
+		return "", false, nil
+
+	case matches("File", ".", "read", "("):
+		args := gemfileCallArgs(expression[4:])
+		if len(args) != 1 || args[0].kind != gemfileString {
+			return "", false, nil
+		}
+		contents, err := d.readFile(args[0].value)
+		if err != nil {
+			return "", false, err
+		}
+		return contents, true, nil
+	}
+
+	return "", false, nil
+}
+
+// readFile returns the contents of a file the Gemfile reads the Ruby version
+// from. The file must be located in the application directory, and a missing
+// file is an error of the Gemfile rather than a missing version source.
+func (d gemfileRubyDeclaration) readFile(path string) (string, error) {
+	relPath, err := filepath.Rel(d.dir, filepath.Join(d.dir, path))
+	if filepath.IsAbs(path) || err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("the file '%s' referenced by the Gemfile is outside of the application directory", path)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(d.dir, relPath))
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("reading the file '%s' referenced by the Gemfile failed: %s", path, err)
 	}
-	defer gemfile.Close()
+	return strings.TrimSpace(string(contents)), nil
+}
 
-	scanner := bufio.NewScanner(gemfile)
-	re, err := regexp.Compile(RubyVersionRegEx)
-	if err == nil {
-		for scanner.Scan() {
-			rubySubSlices := re.FindSubmatch([]byte(scanner.Text()))
-			if rubySubSlices != nil {
-				return string(rubySubSlices[1]), nil
+// gemfileKeywordArg splits an argument into the keyword (if any) and the
+// expression, supporting both `key: value` and `:key => value`
+func gemfileKeywordArg(arg []gemfileToken) (string, []gemfileToken) {
+	if len(arg) > 1 && arg[0].kind == gemfileLabel {
+		return arg[0].value, arg[1:]
+	}
+	if len(arg) > 2 && arg[0].kind == gemfileSymbol && arg[1].value == "=>" {
+		return arg[0].value, arg[2:]
+	}
+	return "", arg
+}
+
+// gemfileCallArgs returns the single-token arguments of a method call up to
+// the closing bracket; any trailing method calls like ".strip" are ignored
+func gemfileCallArgs(tokens []gemfileToken) []gemfileToken {
+	var args []gemfileToken
+	for _, token := range tokens {
+		if token.kind == gemfilePunctuation {
+			if token.value == ")" {
+				break
+			}
+			continue
+		}
+		args = append(args, token)
+	}
+	return args
+}
+
+func gemfileExpressionString(args [][]gemfileToken) string {
+	var parts []string
+	for _, arg := range args {
+		var builder strings.Builder
+		for _, token := range arg {
+			switch token.kind {
+			case gemfileString, gemfileInterpolatedString:
+				builder.WriteString(`"` + token.value + `"`)
+			case gemfileSymbol:
+				builder.WriteString(":" + token.value)
+			case gemfileLabel:
+				builder.WriteString(token.value + ": ")
+			default:
+				builder.WriteString(token.value)
+			}
+		}
+		parts = append(parts, builder.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// RubyVersionFromRequirements converts a list of RubyGems style version
+// requirements like "~> 3.1" or ">= 2.7, < 3.2" into a version string that
+// RVM can install. RVM resolves partial versions like "3.1" to the latest
+// matching patch release. An empty string is returned if no lower bound
+// could be found.
+func RubyVersionFromRequirements(requirements []string) string {
+	var lowerBound string
+
+	for _, requirement := range requirements {
+		for _, part := range strings.Split(requirement, ",") {
+			operator, version := splitRequirement(part)
+			if version == "" {
+				continue
+			}
+
+			switch operator {
+			case "", "=":
+				return version
+			case "~>":
+				// "~> 3.1" allows 3.1 or any later 3.x, "~> 3.1.2" only 3.1.x
+				if lowerBound == "" {
+					segments := strings.Split(version, ".")
+					if len(segments) > 2 {
+						segments = segments[:len(segments)-1]
+					}
+					lowerBound = strings.Join(segments, ".")
+				}
+			case ">=", ">":
+				if lowerBound == "" {
+					lowerBound = version
+				}
 			}
 		}
 	}
 
-	return "", nil
+	return lowerBound
+}
+
+func splitRequirement(requirement string) (string, string) {
+	requirement = strings.TrimSpace(requirement)
+	for _, operator := range []string{"~>", ">=", "<=", "!=", ">", "<", "="} {
+		if strings.HasPrefix(requirement, operator) {
+			return operator, strings.TrimSpace(strings.TrimPrefix(requirement, operator))
+		}
+	}
+	return "", requirement
 }
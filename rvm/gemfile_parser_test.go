@@ -1,6 +1,7 @@
 package rvm_test
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -17,33 +18,187 @@ func testGemFileParser(t *testing.T, context spec.G, it spec.S) {
 		Expect = NewWithT(t).Expect
 
 		workDir       string
+		logs          *bytes.Buffer
 		gemFileParser rvm.GemfileParser
 	)
 
-	context("when a Gemfile.lock is present", func() {
+	it.Before(func() {
+		var err error
+
+		workDir, err = ioutil.TempDir("", "workDir")
+		Expect(err).NotTo(HaveOccurred())
+
+		logs = bytes.NewBuffer(nil)
+		gemFileParser = rvm.NewGemfileParser(rvm.NewLogEmitter(logs))
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workDir)).To(Succeed())
+	})
+
+	context("when a Gemfile is present", func() {
 		it.Before(func() {
-			var err error
+			gemFile, err := ioutil.ReadFile("../test/fixtures/read_version_gemfile/Gemfile")
+			Expect(err).NotTo(HaveOccurred())
 
-			workDir, err = ioutil.TempDir("", "workDir")
+			err = ioutil.WriteFile(filepath.Join(workDir, "Gemfile"), gemFile, 0644)
 			Expect(err).NotTo(HaveOccurred())
+		})
 
-			gemFileLock, err := ioutil.ReadFile("../test/fixtures/read_version_gemfile/Gemfile")
+		it("returns the ruby version after parsing the Gemfile", func() {
+			rubyVersion, err := gemFileParser.ParseVersion(filepath.Join(workDir, "Gemfile"))
 			Expect(err).NotTo(HaveOccurred())
+			Expect(rubyVersion).To(Equal("2.6.5"))
+		})
+	})
 
-			err = ioutil.WriteFile(filepath.Join(workDir, "Gemfile"), gemFileLock, 0644)
+	context("when the Gemfile declares the ruby version in different forms", func() {
+		parse := func(gemfile string) string {
+			err := ioutil.WriteFile(filepath.Join(workDir, "Gemfile"), []byte(gemfile), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			rubyVersion, err := gemFileParser.ParseVersion(filepath.Join(workDir, "Gemfile"))
 			Expect(err).NotTo(HaveOccurred())
+			return rubyVersion
+		}
 
-			gemFileParser = rvm.NewGemfileParser()
+		it("understands parentheses, single quotes and trailing comments", func() {
+			Expect(parse(`ruby('3.1.2') # pinned`)).To(Equal("3.1.2"))
 		})
 
-		it("returns the ruby version after parsing Gemfile.lock", func() {
-			rubyVersion, err := gemFileParser.ParseVersion(filepath.Join(workDir, "Gemfile"))
+		it("understands indented declarations and trailing conditions", func() {
+			Expect(parse(`source "https://rubygems.org"
+
+group :production do
+  ruby "3.1.2"
+end
+`)).To(Equal("3.1.2"))
+			Expect(parse(`ruby "3.0.4" if RUBY_ENGINE == "ruby"`)).To(Equal("3.0.4"))
+		})
+
+		it("warns about declarations within conditional blocks and returns an empty version", func() {
+			Expect(parse(`source "https://rubygems.org"
+
+if ENV["LEGACY"]
+  ruby "2.7.6"
+else
+  ruby "3.1.2"
+end
+`)).To(BeEmpty())
+			Expect(logs.String()).To(ContainSubstring(`Warning: unsupported dynamic Ruby version declaration in Gemfile: ruby "2.7.6" within a conditional block`))
+
+			Expect(parse(`case ENV["RUBY_FLAVOR"]
+when "legacy" then ruby "2.7.6"
+end
+`)).To(BeEmpty())
+			Expect(parse(`unless ENV["LEGACY"] then ruby "3.1.2" end`)).To(BeEmpty())
+		})
+
+		it("finds declarations after closed conditional blocks", func() {
+			Expect(parse(`if ENV["CI"]
+  gem "rspec_junit_formatter"
+end
+ruby "3.1.2"
+`)).To(Equal("3.1.2"))
+		})
+
+		it("ignores identifiers that only look like a declaration", func() {
+			Expect(parse(`ruby_version = "2.5.0"
+gem "ruby"
+# ruby "2.4.0"
+ruby "3.2.0"
+`)).To(Equal("3.2.0"))
+		})
+
+		it("follows the file: indirection", func() {
+			err := ioutil.WriteFile(filepath.Join(workDir, ".ruby-version"), []byte("3.1.3\n"), 0644)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(rubyVersion).To(Equal("2.6.5"))
+
+			Expect(parse(`ruby file: ".ruby-version"`)).To(Equal("3.1.3"))
+			Expect(parse(`ruby :file => ".ruby-version"`)).To(Equal("3.1.3"))
+			Expect(parse(`ruby File.read(".ruby-version").strip`)).To(Equal("3.1.3"))
+		})
+
+		it("evaluates environment variable lookups", func() {
+			Expect(parse(`ruby ENV.fetch("RVM_CNB_TEST_RUBY", "3.0.1")`)).To(Equal("3.0.1"))
+
+			Expect(os.Setenv("RVM_CNB_TEST_RUBY", "2.7.2")).To(Succeed())
+			defer os.Unsetenv("RVM_CNB_TEST_RUBY")
+
+			Expect(parse(`ruby ENV.fetch("RVM_CNB_TEST_RUBY", "3.0.1")`)).To(Equal("2.7.2"))
+			Expect(parse(`ruby ENV["RVM_CNB_TEST_RUBY"]`)).To(Equal("2.7.2"))
+		})
+
+		it("converts requirements into an installable version", func() {
+			Expect(parse(`ruby "~> 3.1"`)).To(Equal("3.1"))
+			Expect(parse(`ruby "~> 3"`)).To(Equal("3"))
+			Expect(parse(`ruby "~> 3.1.2"`)).To(Equal("3.1"))
+			Expect(parse(`ruby ">= 2.7.0", "< 3.2"`)).To(Equal("2.7.0"))
+			Expect(parse(`ruby "2.6.5", patchlevel: "114"`)).To(Equal("2.6.5-p114"))
+		})
+
+		it("returns the requirements the version was derived from", func() {
+			err := ioutil.WriteFile(filepath.Join(workDir, "Gemfile"), []byte(`ruby ">= 2.7.0", "< 3.2"`), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			rubyVersion, requirement, err := gemFileParser.ParseRequirement(filepath.Join(workDir, "Gemfile"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rubyVersion).To(Equal("2.7.0"))
+			Expect(requirement).To(Equal(">= 2.7.0, < 3.2"))
+
+			err = ioutil.WriteFile(filepath.Join(workDir, "Gemfile"), []byte(`ruby "3.1.2"`), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, requirement, err = gemFileParser.ParseRequirement(filepath.Join(workDir, "Gemfile"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requirement).To(BeEmpty())
+		})
+
+		it("handles alternative engines spread over multiple lines", func() {
+			Expect(parse(`ruby "2.6.8",
+  engine: "jruby",
+  engine_version: "9.3.4.0"
+`)).To(Equal("jruby-9.3.4.0"))
+		})
+
+		it("warns about dynamic expressions and returns an empty version", func() {
+			Expect(parse(`ruby RUBY_VERSION`)).To(BeEmpty())
+			Expect(logs.String()).To(ContainSubstring("Warning: unsupported dynamic Ruby version declaration in Gemfile: ruby RUBY_VERSION"))
+		})
+
+		it("warns about requirements without a lower bound", func() {
+			Expect(parse(`ruby "< 3.0"`)).To(BeEmpty())
+			Expect(logs.String()).To(ContainSubstring("Warning: unable to derive an installable Ruby version from Gemfile requirements '< 3.0'"))
+		})
+
+		it("returns an empty version when there is no declaration", func() {
+			Expect(parse(`source "https://rubygems.org"`)).To(BeEmpty())
 		})
+	})
+
+	context("failure cases", func() {
+		it("returns an error when the Gemfile does not exist", func() {
+			_, err := gemFileParser.ParseVersion(filepath.Join(workDir, "Gemfile"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		it("returns an error when the file referenced by file: does not exist", func() {
+			err := ioutil.WriteFile(filepath.Join(workDir, "Gemfile"), []byte(`ruby file: ".ruby-version"`), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = gemFileParser.ParseVersion(filepath.Join(workDir, "Gemfile"))
+			Expect(err).To(MatchError(ContainSubstring("reading the file '.ruby-version' referenced by the Gemfile failed")))
+			Expect(os.IsNotExist(err)).To(BeFalse())
+		})
+
+		it("returns an error when a file outside of the application directory is referenced", func() {
+			for _, gemfile := range []string{`ruby file: "../.ruby-version"`, `ruby File.read("/etc/hostname")`, `ruby file: "config/../../.ruby-version"`} {
+				err := ioutil.WriteFile(filepath.Join(workDir, "Gemfile"), []byte(gemfile), 0644)
+				Expect(err).NotTo(HaveOccurred())
 
-		it.After(func() {
-			Expect(os.RemoveAll(workDir)).To(Succeed())
+				_, err = gemFileParser.ParseVersion(filepath.Join(workDir, "Gemfile"))
+				Expect(err).To(MatchError(ContainSubstring("referenced by the Gemfile is outside of the application directory")))
+			}
 		})
 	})
 }
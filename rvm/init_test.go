@@ -9,12 +9,33 @@ import (
 
 func TestUnitRvm(t *testing.T) {
 	suite := spec.New("rvm", spec.Report(report.Terminal{}))
+	suite("ArchiveCache", testArchiveCache)
+	suite("CacheKey", testCacheKey)
+	suite("Concurrency", testConcurrency)
 	suite("Configuration", testConfiguration)
 	suite("BuildpackYMLParser", testBuildpackYMLParser)
 	suite("Environment", testEnvironment)
 	suite("GemFileParser", testGemFileParser)
 	suite("GemFileLockParser", testGemFileLockParser)
 	suite("RubyVersionParser", testRubyVersionParser)
+	suite("SystemLibraries", testSystemLibraries)
+	suite("Target", testTarget)
+	suite("ToolVersionsParser", testToolVersionsParser)
+	suite("VersionConflict", testVersionConflict)
+	suite("Verification", testVerification)
+	suite("Keyring", testKeyring)
+	suite("LaunchEnvironment", testLaunchEnvironment)
+	suite("LaunchProcesses", testLaunchProcesses)
+	suite("LayerManifest", testLayerManifest)
+	suite("MakeJobs", testMakeJobs)
+	suite("OpenSSL", testOpenSSL)
+	suite("RubyInstaller", testRubyInstaller)
+	suite("RubyLayer", testRubyLayer)
+	suite("RubyVersions", testRubyVersions)
+	suite("Patches", testPatches)
+	suite("ProjectPath", testProjectPath)
+	suite("Requirements", testRequirements)
+	suite("Download", testDownload)
 	suite("Detect", testDetect)
 	suite.Run(t)
 }
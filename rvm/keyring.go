@@ -0,0 +1,129 @@
+package rvm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// KeysDir is the directory of this buildpack containing the vendored keys
+// used to verify the signature of RVM releases
+const KeysDir = "keys"
+
+// Keyring represents a throwaway GPG keyring in a temporary GNUPGHOME, so the
+// keyring of the build user is neither used nor modified
+type Keyring struct {
+	home string
+}
+
+// NewKeyring creates a new empty keyring
+func NewKeyring() (Keyring, error) {
+	_, err := exec.LookPath("gpg")
+	if err != nil {
+		return Keyring{}, fmt.Errorf("gpg is required to verify the signature of RVM, add it to the build image: %w", err)
+	}
+
+	home, err := ioutil.TempDir("", "gnupg")
+	if err != nil {
+		return Keyring{}, err
+	}
+
+	return Keyring{
+		home: home,
+	}, nil
+}
+
+// Import imports the keys in the given files into the keyring
+func (k Keyring) Import(paths ...string) error {
+	_, err := k.gpg(append([]string{"--import"}, paths...)...)
+	return err
+}
+
+// Fingerprints returns the fingerprints of the primary keys in the keyring
+func (k Keyring) Fingerprints() ([]string, error) {
+	output, err := k.gpg("--with-colons", "--fingerprint")
+	if err != nil {
+		return nil, err
+	}
+	return PrimaryKeyFingerprints(output), nil
+}
+
+// VerifyFingerprints returns an error if the keyring contains a key whose
+// fingerprint is not one of the pinned fingerprints or contains no key at
+// all
+func (k Keyring) VerifyFingerprints(pinned []string) error {
+	fingerprints, err := k.Fingerprints()
+	if err != nil {
+		return err
+	}
+
+	if len(fingerprints) == 0 {
+		return fmt.Errorf("the keyring does not contain any keys")
+	}
+
+	var normalized []string
+	for _, fingerprint := range pinned {
+		normalized = append(normalized, NormalizeFingerprint(fingerprint))
+	}
+
+	for _, fingerprint := range fingerprints {
+		if !contains(normalized, fingerprint) {
+			return fmt.Errorf("the fingerprint %s of the imported key is not pinned", fingerprint)
+		}
+	}
+
+	return nil
+}
+
+// Verify verifies the detached signature of a file using the keys in the
+// keyring
+func (k Keyring) Verify(signaturePath, path string) error {
+	_, err := k.gpg("--verify", signaturePath, path)
+	if err != nil {
+		return fmt.Errorf("invalid signature of %s: %w", path, err)
+	}
+	return nil
+}
+
+// Close removes the keyring
+func (k Keyring) Close() error {
+	return os.RemoveAll(k.home)
+}
+
+// gpg runs gpg using the keyring and returns its combined output
+func (k Keyring) gpg(args ...string) (string, error) {
+	cmd := exec.Command("gpg", append([]string{"--batch", "--no-tty"}, args...)...)
+	cmd.Env = append(os.Environ(), "GNUPGHOME="+k.home)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("gpg %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// PrimaryKeyFingerprints parses the output of "gpg --with-colons" and returns
+// the fingerprints of the primary keys
+func PrimaryKeyFingerprints(output string) []string {
+	var fingerprints []string
+	primaryKey := false
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, ":")
+		switch {
+		case fields[0] == "pub":
+			primaryKey = true
+		case fields[0] == "sub":
+			primaryKey = false
+		case fields[0] == "fpr" && primaryKey && len(fields) > 9:
+			fingerprints = append(fingerprints, NormalizeFingerprint(fields[9]))
+			primaryKey = false
+		}
+	}
+	return fingerprints
+}
+
+// NormalizeFingerprint returns a fingerprint in upper case without spaces
+func NormalizeFingerprint(fingerprint string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(fingerprint), ""))
+}
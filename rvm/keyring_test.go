@@ -0,0 +1,159 @@
+package rvm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testKeyring(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workDir string
+		gpg     func(args ...string) string
+	)
+
+	it.Before(func() {
+		var err error
+		workDir, err = ioutil.TempDir("", "keyring")
+		Expect(err).NotTo(HaveOccurred())
+
+		signerHome := filepath.Join(workDir, "signer")
+		Expect(os.Mkdir(signerHome, 0700)).To(Succeed())
+
+		gpg = func(args ...string) string {
+			cmd := exec.Command("gpg", append([]string{"--batch", "--no-tty", "--pinentry-mode", "loopback", "--passphrase", ""}, args...)...)
+			cmd.Env = append(os.Environ(), "GNUPGHOME="+signerHome)
+			output, err := cmd.Output()
+			Expect(err).NotTo(HaveOccurred())
+			return string(output)
+		}
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workDir)).To(Succeed())
+	})
+
+	context("PrimaryKeyFingerprints", func() {
+		it("returns the fingerprints of primary keys only", func() {
+			output := `tru::1:1660000000:0:3:1:5
+pub:-:4096:1:3804BB82D39DC0E3:1347556567:::-:::scSC::::::23::0:
+fpr:::::::::409B6B1796C275462A1703113804BB82D39DC0E3:
+uid:-::::1347556567::2A1F3F0D9C6D4E3A::Michal Papis (RVM signing) <mpapis@gmail.com>::::::::::0:
+sub:-:4096:1:E1B4F9A47D8D6B0F:1347556567::::::e::::::23:
+fpr:::::::::0AD1DC6A5C5B0E6D3E1C7B2AE1B4F9A47D8D6B0F:
+pub:-:4096:1:105BD0E739499BDB:1528831418:::-:::scESC::::::23::0:
+fpr:::::::::7D2BAF1CF37B13E2069D6956105BD0E739499BDB:
+`
+			Expect(rvm.PrimaryKeyFingerprints(output)).To(Equal([]string{
+				"409B6B1796C275462A1703113804BB82D39DC0E3",
+				"7D2BAF1CF37B13E2069D6956105BD0E739499BDB",
+			}))
+		})
+	})
+
+	context("NormalizeFingerprint", func() {
+		it("removes spaces and converts to upper case", func() {
+			Expect(rvm.NormalizeFingerprint("409b 6b17 96c2 7546 2a17  0311 3804 bb82 d39d c0e3")).To(Equal("409B6B1796C275462A1703113804BB82D39DC0E3"))
+		})
+	})
+
+	context("when gpg is available", func() {
+		var (
+			keyPath     string
+			fingerprint string
+			filePath    string
+		)
+
+		it.Before(func() {
+			if _, err := exec.LookPath("gpg"); err != nil {
+				t.Skip("gpg is not installed")
+			}
+
+			gpg("--quick-gen-key", "RVM CNB Test <test@example.com>", "ed25519", "sign", "never")
+			fingerprint = rvm.PrimaryKeyFingerprints(gpg("--with-colons", "--fingerprint"))[0]
+
+			keyPath = filepath.Join(workDir, "test.asc")
+			Expect(ioutil.WriteFile(keyPath, []byte(gpg("--armor", "--export", fingerprint)), 0644)).To(Succeed())
+
+			filePath = filepath.Join(workDir, "rvm.tar.gz")
+			Expect(ioutil.WriteFile(filePath, []byte("rvm"), 0644)).To(Succeed())
+			gpg("--armor", "--detach-sign", "--output", filePath+".asc", filePath)
+		})
+
+		it("verifies signatures made with pinned keys", func() {
+			keyring, err := rvm.NewKeyring()
+			Expect(err).NotTo(HaveOccurred())
+			defer keyring.Close()
+
+			Expect(keyring.Import(keyPath)).To(Succeed())
+			Expect(keyring.Fingerprints()).To(Equal([]string{fingerprint}))
+			Expect(keyring.VerifyFingerprints([]string{fingerprint})).To(Succeed())
+			Expect(keyring.Verify(filePath+".asc", filePath)).To(Succeed())
+		})
+
+		it("rejects keys that are not pinned", func() {
+			keyring, err := rvm.NewKeyring()
+			Expect(err).NotTo(HaveOccurred())
+			defer keyring.Close()
+
+			Expect(keyring.Import(keyPath)).To(Succeed())
+			Expect(keyring.VerifyFingerprints([]string{"409B6B1796C275462A1703113804BB82D39DC0E3"})).To(MatchError("the fingerprint " + fingerprint + " of the imported key is not pinned"))
+		})
+
+		it("rejects modified files", func() {
+			keyring, err := rvm.NewKeyring()
+			Expect(err).NotTo(HaveOccurred())
+			defer keyring.Close()
+
+			Expect(keyring.Import(keyPath)).To(Succeed())
+			Expect(ioutil.WriteFile(filePath, []byte("modified"), 0644)).To(Succeed())
+			Expect(keyring.Verify(filePath+".asc", filePath)).To(MatchError(ContainSubstring("invalid signature of " + filePath)))
+		})
+
+		it("rejects empty keyrings", func() {
+			keyring, err := rvm.NewKeyring()
+			Expect(err).NotTo(HaveOccurred())
+			defer keyring.Close()
+
+			Expect(keyring.VerifyFingerprints([]string{fingerprint})).To(MatchError("the keyring does not contain any keys"))
+		})
+	})
+
+	context("the keys vendored in the buildpack", func() {
+		it.Before(func() {
+			if _, err := exec.LookPath("gpg"); err != nil {
+				t.Skip("gpg is not installed")
+			}
+		})
+
+		it("match the fingerprints pinned in buildpack.toml", func() {
+			configuration, err := rvm.ReadConfiguration("..")
+			Expect(err).NotTo(HaveOccurred())
+
+			keyPaths := []string{
+				filepath.Join("..", rvm.KeysDir, "mpapis.asc"),
+				filepath.Join("..", rvm.KeysDir, "pkuczynski.asc"),
+			}
+			for _, keyPath := range keyPaths {
+				Expect(keyPath).To(BeAnExistingFile(), "the RVM release keys must be committed to the keys directory, see https://rvm.io/rvm/security")
+			}
+
+			keyring, err := rvm.NewKeyring()
+			Expect(err).NotTo(HaveOccurred())
+			defer keyring.Close()
+
+			Expect(keyring.Import(keyPaths...)).To(Succeed())
+			Expect(keyring.VerifyFingerprints(configuration.RVMKeyFingerprints)).To(Succeed())
+			Expect(keyring.Fingerprints()).To(ConsistOf(configuration.RVMKeyFingerprints))
+		})
+	})
+}
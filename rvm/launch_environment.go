@@ -0,0 +1,184 @@
+package rvm
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RubySelectionEnv is the name of the environment variable that selects one
+// of the installed Ruby versions at launch instead of the RVM default
+const RubySelectionEnv = "RVM_RUBY"
+
+// RVMPaths represents the directories of an RVM installation
+type RVMPaths struct {
+	// RVM is the RVM installation itself, which is not available at launch
+	RVM string
+	// Rubies contains the installed Rubies
+	Rubies string
+	// Gems contains the gems of the installed Rubies
+	Gems string
+}
+
+// RVMPathsFromEnv returns the directories of the RVM installation set in the
+// environment variables rvm_path, rvm_rubies_path and rvm_gems_path
+func RVMPathsFromEnv() RVMPaths {
+	paths := RVMPaths{
+		RVM:    os.Getenv("rvm_path"),
+		Rubies: os.Getenv("rvm_rubies_path"),
+		Gems:   os.Getenv("rvm_gems_path"),
+	}
+	if paths.RVM != "" && paths.Rubies == "" {
+		paths.Rubies = filepath.Join(paths.RVM, "rubies")
+	}
+	if paths.RVM != "" && paths.Gems == "" {
+		paths.Gems = filepath.Join(paths.RVM, "gems")
+	}
+	return paths
+}
+
+// LaunchEnvironment returns the environment variables that RVM would set
+// when selecting a Ruby, so that processes can use Ruby without sourcing the
+// RVM shell scripts. The Ruby is selected by the given name, which may be a
+// partial version like "2.7", or the RVM default alias if the name is empty.
+func LaunchEnvironment(paths RVMPaths, rubyName, path string) (map[string]string, error) {
+	if paths.Rubies == "" || paths.Gems == "" {
+		return nil, fmt.Errorf("neither rvm_path nor rvm_rubies_path and rvm_gems_path are set")
+	}
+
+	if rubyName == "" {
+		if paths.RVM == "" {
+			return nil, fmt.Errorf("%s is not set and the RVM default Ruby is not available", RubySelectionEnv)
+		}
+
+		defaultRuby, err := defaultRubyAlias(paths.RVM)
+		if err != nil {
+			return nil, err
+		}
+		rubyName = defaultRuby
+	}
+
+	ruby, err := findInstalledRuby(paths.Rubies, rubyName)
+	if err != nil {
+		return nil, err
+	}
+
+	rubyHome := filepath.Join(paths.Rubies, ruby)
+	gemHome := filepath.Join(paths.Gems, ruby)
+	globalGemHome := filepath.Join(paths.Gems, ruby+"@global")
+
+	binPaths := []string{
+		filepath.Join(gemHome, "bin"),
+		filepath.Join(globalGemHome, "bin"),
+		filepath.Join(rubyHome, "bin"),
+	}
+	if paths.RVM != "" {
+		binPaths = append(binPaths, filepath.Join(paths.RVM, "bin"))
+	}
+	if path != "" {
+		binPaths = append(binPaths, path)
+	}
+
+	return map[string]string{
+		"PATH":         strings.Join(binPaths, string(os.PathListSeparator)),
+		"GEM_HOME":     gemHome,
+		"GEM_PATH":     strings.Join([]string{gemHome, globalGemHome}, string(os.PathListSeparator)),
+		"MY_RUBY_HOME": rubyHome,
+		"IRBRC":        filepath.Join(rubyHome, ".irbrc"),
+		"RUBY_VERSION": ruby,
+	}, nil
+}
+
+// defaultRubyAlias reads the Ruby the RVM alias "default" points to
+func defaultRubyAlias(rvmPath string) (string, error) {
+	aliasPath := filepath.Join(rvmPath, "config", "alias")
+	file, err := os.Open(aliasPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the RVM default Ruby: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if found && name == "default" && value != "" {
+			return value, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("no RVM default Ruby found in %s", aliasPath)
+}
+
+// findInstalledRuby returns the name of the directory in the rubies path
+// that matches the given Ruby name. Partial versions select the most recent
+// matching Ruby, e.g. "2.7" selects "ruby-2.7.6" over "ruby-2.7.2".
+func findInstalledRuby(rubiesPath, rubyName string) (string, error) {
+	files, err := ioutil.ReadDir(rubiesPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to list installed Rubies: %w", err)
+	}
+
+	var installed []string
+	for _, file := range files {
+		if file.IsDir() {
+			installed = append(installed, file.Name())
+		}
+	}
+
+	name := rubyName
+	if strings.ContainsAny(name[:1], "0123456789") {
+		name = "ruby-" + name
+	}
+
+	var matches []string
+	for _, ruby := range installed {
+		if ruby == name {
+			return ruby, nil
+		}
+		if strings.HasPrefix(ruby, name+".") || strings.HasPrefix(ruby, name+"-") {
+			matches = append(matches, ruby)
+		}
+	}
+
+	if len(matches) == 0 {
+		return "", fmt.Errorf("Ruby '%s' is not installed, installed Rubies: %s", rubyName, strings.Join(installed, ", "))
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return compareVersionSegments(matches[i], matches[j]) > 0
+	})
+	return matches[0], nil
+}
+
+// compareVersionSegments compares two version strings segment by segment,
+// numerically where possible
+func compareVersionSegments(a, b string) int {
+	split := func(version string) []string {
+		return strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '-' })
+	}
+	segmentsA, segmentsB := split(a), split(b)
+
+	for i := 0; i < len(segmentsA) && i < len(segmentsB); i++ {
+		numberA, errA := strconv.Atoi(strings.TrimPrefix(segmentsA[i], "p"))
+		numberB, errB := strconv.Atoi(strings.TrimPrefix(segmentsB[i], "p"))
+		switch {
+		case errA == nil && errB == nil && numberA != numberB:
+			if numberA < numberB {
+				return -1
+			}
+			return 1
+		case (errA != nil || errB != nil) && segmentsA[i] != segmentsB[i]:
+			return strings.Compare(segmentsA[i], segmentsB[i])
+		}
+	}
+
+	return len(segmentsA) - len(segmentsB)
+}
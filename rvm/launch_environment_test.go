@@ -0,0 +1,142 @@
+package rvm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLaunchEnvironment(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		rvmPath string
+		paths   rvm.RVMPaths
+	)
+
+	it.Before(func() {
+		var err error
+		rvmPath, err = ioutil.TempDir("", "rvm")
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, ruby := range []string{"ruby-2.7.2", "ruby-2.7.10", "ruby-3.1.2"} {
+			Expect(os.MkdirAll(filepath.Join(rvmPath, "rubies", ruby), os.ModePerm)).To(Succeed())
+		}
+
+		Expect(os.MkdirAll(filepath.Join(rvmPath, "config"), os.ModePerm)).To(Succeed())
+		err = ioutil.WriteFile(filepath.Join(rvmPath, "config", "alias"), []byte("default=ruby-3.1.2\n"), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		paths = rvm.RVMPaths{
+			RVM:    rvmPath,
+			Rubies: filepath.Join(rvmPath, "rubies"),
+			Gems:   filepath.Join(rvmPath, "gems"),
+		}
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(rvmPath)).To(Succeed())
+	})
+
+	it("returns the environment of the RVM default Ruby", func() {
+		environment, err := rvm.LaunchEnvironment(paths, "", "/usr/bin:/bin")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(environment).To(Equal(map[string]string{
+			"PATH": filepath.Join(rvmPath, "gems", "ruby-3.1.2", "bin") + ":" +
+				filepath.Join(rvmPath, "gems", "ruby-3.1.2@global", "bin") + ":" +
+				filepath.Join(rvmPath, "rubies", "ruby-3.1.2", "bin") + ":" +
+				filepath.Join(rvmPath, "bin") + ":/usr/bin:/bin",
+			"GEM_HOME":     filepath.Join(rvmPath, "gems", "ruby-3.1.2"),
+			"GEM_PATH":     filepath.Join(rvmPath, "gems", "ruby-3.1.2") + ":" + filepath.Join(rvmPath, "gems", "ruby-3.1.2@global"),
+			"MY_RUBY_HOME": filepath.Join(rvmPath, "rubies", "ruby-3.1.2"),
+			"IRBRC":        filepath.Join(rvmPath, "rubies", "ruby-3.1.2", ".irbrc"),
+			"RUBY_VERSION": "ruby-3.1.2",
+		}))
+	})
+
+	it("selects the most recent Ruby matching a partial version", func() {
+		environment, err := rvm.LaunchEnvironment(paths, "2.7", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(environment["RUBY_VERSION"]).To(Equal("ruby-2.7.10"))
+	})
+
+	it("selects a Ruby by its full name", func() {
+		environment, err := rvm.LaunchEnvironment(paths, "ruby-2.7.2", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(environment["RUBY_VERSION"]).To(Equal("ruby-2.7.2"))
+	})
+
+	context("when only the Ruby launch layer is available", func() {
+		it.Before(func() {
+			paths.RVM = ""
+		})
+
+		it("does not add the RVM scripts to the PATH", func() {
+			environment, err := rvm.LaunchEnvironment(paths, "3.1", "/usr/bin")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(environment["PATH"]).To(Equal(filepath.Join(rvmPath, "gems", "ruby-3.1.2", "bin") + ":" +
+				filepath.Join(rvmPath, "gems", "ruby-3.1.2@global", "bin") + ":" +
+				filepath.Join(rvmPath, "rubies", "ruby-3.1.2", "bin") + ":/usr/bin"))
+		})
+
+		it("returns an error if no Ruby is selected", func() {
+			_, err := rvm.LaunchEnvironment(paths, "", "")
+			Expect(err).To(MatchError("RVM_RUBY is not set and the RVM default Ruby is not available"))
+		})
+	})
+
+	context("RVMPathsFromEnv", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("rvm_path")).To(Succeed())
+			Expect(os.Unsetenv("rvm_rubies_path")).To(Succeed())
+			Expect(os.Unsetenv("rvm_gems_path")).To(Succeed())
+		})
+
+		it("derives the rubies and gems paths from rvm_path", func() {
+			Expect(os.Setenv("rvm_path", "/layers/rvm")).To(Succeed())
+
+			Expect(rvm.RVMPathsFromEnv()).To(Equal(rvm.RVMPaths{
+				RVM:    "/layers/rvm",
+				Rubies: "/layers/rvm/rubies",
+				Gems:   "/layers/rvm/gems",
+			}))
+		})
+
+		it("prefers rvm_rubies_path and rvm_gems_path", func() {
+			Expect(os.Setenv("rvm_path", "/layers/rvm")).To(Succeed())
+			Expect(os.Setenv("rvm_rubies_path", "/layers/ruby/rubies")).To(Succeed())
+			Expect(os.Setenv("rvm_gems_path", "/layers/ruby/gems")).To(Succeed())
+
+			Expect(rvm.RVMPathsFromEnv()).To(Equal(rvm.RVMPaths{
+				RVM:    "/layers/rvm",
+				Rubies: "/layers/ruby/rubies",
+				Gems:   "/layers/ruby/gems",
+			}))
+		})
+	})
+
+	context("failure cases", func() {
+		it("returns an error if neither rvm_path nor the rubies and gems paths are set", func() {
+			_, err := rvm.LaunchEnvironment(rvm.RVMPaths{}, "", "")
+			Expect(err).To(MatchError("neither rvm_path nor rvm_rubies_path and rvm_gems_path are set"))
+		})
+
+		it("returns an error if the selected Ruby is not installed", func() {
+			_, err := rvm.LaunchEnvironment(paths, "3.2", "")
+			Expect(err).To(MatchError("Ruby '3.2' is not installed, installed Rubies: ruby-2.7.10, ruby-2.7.2, ruby-3.1.2"))
+		})
+
+		it("returns an error if there is no default Ruby", func() {
+			Expect(os.Remove(filepath.Join(rvmPath, "config", "alias"))).To(Succeed())
+
+			_, err := rvm.LaunchEnvironment(paths, "", "")
+			Expect(err).To(MatchError(ContainSubstring("failed to read the RVM default Ruby")))
+		})
+	})
+}
@@ -0,0 +1,91 @@
+package rvm
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// StartCommandEnv is the name of the environment variable that sets the
+// command of the default "web" process
+const StartCommandEnv = "BP_RVM_START_COMMAND"
+
+// LaunchProcesses detects the processes to start the app in the given project
+// path. In order of precedence the command is taken from the environment
+// variable BP_RVM_START_COMMAND, a Procfile or a rackup file (config.ru). The
+// "web" process is the default process. No processes are returned if none of
+// these exist.
+func LaunchProcesses(projectPath string) ([]packit.Process, error) {
+	if command := os.Getenv(StartCommandEnv); command != "" {
+		return []packit.Process{webProcess(command)}, nil
+	}
+
+	processes, err := procfileProcesses(filepath.Join(projectPath, "Procfile"))
+	if err != nil {
+		return nil, err
+	}
+	if len(processes) > 0 {
+		return processes, nil
+	}
+
+	if fileExists(filepath.Join(projectPath, "config.ru")) {
+		command := `rackup --port "${PORT:-8080}" --host 0.0.0.0`
+		if fileExists(filepath.Join(projectPath, GemfileSource)) {
+			command = "bundle exec " + command
+		}
+		return []packit.Process{webProcess(command)}, nil
+	}
+
+	return nil, nil
+}
+
+// procfileProcesses reads processes from a Procfile consisting of lines like
+// "web: bundle exec puma"
+func procfileProcesses(path string) ([]packit.Process, error) {
+	procfile, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer procfile.Close()
+
+	var processes []packit.Process
+	scanner := bufio.NewScanner(procfile)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		processType, command, found := strings.Cut(line, ":")
+		processType, command = strings.TrimSpace(processType), strings.TrimSpace(command)
+		if !found || processType == "" || command == "" {
+			continue
+		}
+
+		process := webProcess(command)
+		process.Type = processType
+		process.Default = processType == "web"
+		processes = append(processes, process)
+	}
+
+	return processes, scanner.Err()
+}
+
+// webProcess returns the default process. Since Buildpack API 0.9 processes
+// are not run by a shell anymore, so the command is passed to bash, which
+// also expands variables like $PORT.
+func webProcess(command string) packit.Process {
+	return packit.Process{
+		Type:    "web",
+		Command: "bash",
+		Args:    []string{"-c", command},
+		Direct:  true,
+		Default: true,
+	}
+}
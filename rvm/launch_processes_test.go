@@ -0,0 +1,73 @@
+package rvm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLaunchProcesses(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		projectPath string
+	)
+
+	it.Before(func() {
+		var err error
+		projectPath, err = ioutil.TempDir("", "project")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.Unsetenv("BP_RVM_START_COMMAND")).To(Succeed())
+		Expect(os.RemoveAll(projectPath)).To(Succeed())
+	})
+
+	it("returns no processes if the start command cannot be detected", func() {
+		processes, err := rvm.LaunchProcesses(projectPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(processes).To(BeEmpty())
+	})
+
+	it("returns the processes listed in a Procfile", func() {
+		err := ioutil.WriteFile(filepath.Join(projectPath, "Procfile"), []byte("# processes\nweb: bundle exec puma -C config/puma.rb\nworker:  bundle exec sidekiq\n\n"), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		processes, err := rvm.LaunchProcesses(projectPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(processes).To(Equal([]packit.Process{
+			{Type: "web", Command: "bash", Args: []string{"-c", "bundle exec puma -C config/puma.rb"}, Direct: true, Default: true},
+			{Type: "worker", Command: "bash", Args: []string{"-c", "bundle exec sidekiq"}, Direct: true},
+		}))
+	})
+
+	it("returns a rackup process for apps with a config.ru", func() {
+		Expect(ioutil.WriteFile(filepath.Join(projectPath, "config.ru"), []byte{}, 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(projectPath, "Gemfile"), []byte{}, 0644)).To(Succeed())
+
+		processes, err := rvm.LaunchProcesses(projectPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(processes).To(Equal([]packit.Process{
+			{Type: "web", Command: "bash", Args: []string{"-c", `bundle exec rackup --port "${PORT:-8080}" --host 0.0.0.0`}, Direct: true, Default: true},
+		}))
+	})
+
+	it("prefers the start command set by BP_RVM_START_COMMAND", func() {
+		Expect(ioutil.WriteFile(filepath.Join(projectPath, "Procfile"), []byte("web: ruby app.rb\n"), 0644)).To(Succeed())
+		Expect(os.Setenv("BP_RVM_START_COMMAND", "ruby run.rb")).To(Succeed())
+
+		processes, err := rvm.LaunchProcesses(projectPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(processes).To(Equal([]packit.Process{
+			{Type: "web", Command: "bash", Args: []string{"-c", "ruby run.rb"}, Direct: true, Default: true},
+		}))
+	})
+}
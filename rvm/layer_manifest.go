@@ -0,0 +1,129 @@
+package rvm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// RVMLayerManifestPatterns match the files of the RVM layer that are
+// verified before a cached RVM layer is reused
+var RVMLayerManifestPatterns = []string{
+	filepath.Join("bin", "rvm"),
+	filepath.Join("scripts", "rvm"),
+}
+
+// RubyLayerManifestPatterns match the files of the Ruby layer that are
+// verified before a cached Ruby layer is reused
+var RubyLayerManifestPatterns = []string{
+	filepath.Join("rubies", "*", "bin", "ruby"),
+	filepath.Join("rubies", "*", "lib", "*.so*"),
+}
+
+// LayerManifest maps the paths of files relative to a layer to their SHA256
+// checksums
+type LayerManifest map[string]string
+
+// NewLayerManifest computes the checksums of all files of the layer matching
+// the given patterns
+func NewLayerManifest(path string, patterns []string) (LayerManifest, error) {
+	manifest := LayerManifest{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.Mode().IsRegular() {
+				continue
+			}
+
+			checksum, err := fileChecksum(match)
+			if err != nil {
+				return nil, err
+			}
+
+			relativePath, err := filepath.Rel(path, match)
+			if err != nil {
+				return nil, err
+			}
+			manifest[relativePath] = checksum
+		}
+	}
+	return manifest, nil
+}
+
+// LayerManifestFromMetadata converts a manifest read from layer metadata
+func LayerManifestFromMetadata(value interface{}) LayerManifest {
+	manifest := LayerManifest{}
+	switch files := value.(type) {
+	case LayerManifest:
+		for file, checksum := range files {
+			manifest[file] = checksum
+		}
+	case map[string]string:
+		for file, checksum := range files {
+			manifest[file] = checksum
+		}
+	case map[string]interface{}:
+		for file, checksum := range files {
+			manifest[file] = fmt.Sprintf("%v", checksum)
+		}
+	}
+	return manifest
+}
+
+// Verify returns an error if the manifest is empty or any of its files is
+// missing from the layer or has a different checksum
+func (m LayerManifest) Verify(path string) error {
+	if len(m) == 0 {
+		return fmt.Errorf("the layer has no manifest")
+	}
+
+	files := make([]string, 0, len(m))
+	for file := range m {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		checksum, err := fileChecksum(filepath.Join(path, file))
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s is missing", file)
+		}
+		if err != nil {
+			return err
+		}
+		if checksum != m[file] {
+			return fmt.Errorf("%s has been modified", file)
+		}
+	}
+
+	return nil
+}
+
+// fileChecksum returns the hex encoded SHA256 checksum of a file
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
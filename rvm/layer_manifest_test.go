@@ -0,0 +1,96 @@
+package rvm_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLayerManifest(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		layerPath string
+	)
+
+	writeFile := func(path, content string) {
+		path = filepath.Join(layerPath, path)
+		Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	checksum := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+
+	it.Before(func() {
+		var err error
+		layerPath, err = ioutil.TempDir("", "ruby")
+		Expect(err).NotTo(HaveOccurred())
+
+		writeFile("rubies/ruby-3.1.2/bin/ruby", "ruby")
+		writeFile("rubies/ruby-3.1.2/bin/irb", "irb")
+		writeFile("rubies/ruby-3.1.2/lib/libruby.so.3.1.2", "libruby")
+		Expect(os.Symlink("libruby.so.3.1.2", filepath.Join(layerPath, "rubies/ruby-3.1.2/lib/libruby.so"))).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(layerPath)).To(Succeed())
+	})
+
+	it("computes the checksums of the files matching the patterns", func() {
+		manifest, err := rvm.NewLayerManifest(layerPath, rvm.RubyLayerManifestPatterns)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest).To(Equal(rvm.LayerManifest{
+			"rubies/ruby-3.1.2/bin/ruby":             checksum("ruby"),
+			"rubies/ruby-3.1.2/lib/libruby.so":       checksum("libruby"),
+			"rubies/ruby-3.1.2/lib/libruby.so.3.1.2": checksum("libruby"),
+		}))
+	})
+
+	it("converts a manifest read from layer metadata", func() {
+		manifest := rvm.LayerManifestFromMetadata(map[string]interface{}{
+			"rubies/ruby-3.1.2/bin/ruby": checksum("ruby"),
+		})
+		Expect(manifest).To(Equal(rvm.LayerManifest{
+			"rubies/ruby-3.1.2/bin/ruby": checksum("ruby"),
+		}))
+	})
+
+	context("Verify", func() {
+		var manifest rvm.LayerManifest
+
+		it.Before(func() {
+			var err error
+			manifest, err = rvm.NewLayerManifest(layerPath, rvm.RubyLayerManifestPatterns)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("succeeds if the layer is unchanged", func() {
+			Expect(manifest.Verify(layerPath)).To(Succeed())
+		})
+
+		it("fails if a file is missing", func() {
+			Expect(os.Remove(filepath.Join(layerPath, "rubies/ruby-3.1.2/bin/ruby"))).To(Succeed())
+			Expect(manifest.Verify(layerPath)).To(MatchError("rubies/ruby-3.1.2/bin/ruby is missing"))
+		})
+
+		it("fails if a file has been modified", func() {
+			writeFile("rubies/ruby-3.1.2/lib/libruby.so.3.1.2", "truncated")
+			Expect(manifest.Verify(layerPath)).To(MatchError("rubies/ruby-3.1.2/lib/libruby.so has been modified"))
+		})
+
+		it("fails if there is no manifest", func() {
+			Expect(rvm.LayerManifest{}.Verify(layerPath)).To(MatchError("the layer has no manifest"))
+		})
+	})
+}
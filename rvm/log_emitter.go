@@ -2,6 +2,7 @@ package rvm
 
 import (
 	"io"
+	"strconv"
 
 	"github.com/paketo-buildpacks/packit/v2/scribe"
 )
@@ -17,3 +18,20 @@ func NewLogEmitter(output io.Writer) LogEmitter {
 		Logger: scribe.NewLogger(output),
 	}
 }
+
+// VersionCandidates prints a table of the Ruby versions found in each version
+// source
+func (l LogEmitter) VersionCandidates(candidates []VersionCandidate) {
+	l.Detail("Ruby version sources:")
+
+	var maxLen int
+	for _, candidate := range candidates {
+		if len(candidate.Source) > maxLen {
+			maxLen = len(candidate.Source)
+		}
+	}
+
+	for _, candidate := range candidates {
+		l.Subdetail("%-"+strconv.Itoa(maxLen)+"s -> %q", candidate.Source, candidate.Requested())
+	}
+}
@@ -0,0 +1,84 @@
+package rvm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MakeJobsEnv is the name of the environment variable that overrides the
+// number of jobs make runs in parallel while compiling Ruby
+const MakeJobsEnv = "BP_RVM_MAKE_JOBS"
+
+// CgroupPath is the mount point of the cgroup file system
+const CgroupPath = "/sys/fs/cgroup"
+
+// CPUQuota returns the number of CPUs the cgroup of the build container may
+// use, read from cpu.max of cgroup v2 or cpu.cfs_quota_us and
+// cpu.cfs_period_us of cgroup v1. It returns false if there is no quota.
+func CPUQuota(cgroupPath string) (float64, bool) {
+	if content, err := ioutil.ReadFile(filepath.Join(cgroupPath, "cpu.max")); err == nil {
+		fields := strings.Fields(string(content))
+		if len(fields) != 2 {
+			return 0, false
+		}
+		return cpuQuota(fields[0], fields[1])
+	}
+
+	for _, controller := range []string{"cpu", "cpu,cpuacct"} {
+		quota, err := ioutil.ReadFile(filepath.Join(cgroupPath, controller, "cpu.cfs_quota_us"))
+		if err != nil {
+			continue
+		}
+		period, err := ioutil.ReadFile(filepath.Join(cgroupPath, controller, "cpu.cfs_period_us"))
+		if err != nil {
+			continue
+		}
+		return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+	}
+
+	return 0, false
+}
+
+// cpuQuota divides a quota by its period, "max" and negative quotas mean
+// that there is no quota
+func cpuQuota(quota, period string) (float64, bool) {
+	quotaValue, err := strconv.ParseFloat(quota, 64)
+	if err != nil || quotaValue <= 0 {
+		return 0, false
+	}
+	periodValue, err := strconv.ParseFloat(period, 64)
+	if err != nil || periodValue <= 0 {
+		return 0, false
+	}
+	return quotaValue / periodValue, true
+}
+
+// MakeJobs returns the number of make jobs and where it was taken from. It
+// can be set using the environment variable BP_RVM_MAKE_JOBS, otherwise the
+// CPU quota of the cgroup rounded up is used, limited by the number of CPUs.
+func MakeJobs(value, cgroupPath string, cpus int) (int, string, error) {
+	if value != "" {
+		jobs, err := strconv.Atoi(value)
+		if err != nil || jobs < 1 {
+			return 0, "", fmt.Errorf("invalid value '%s' for %s, expected a positive number", value, MakeJobsEnv)
+		}
+		return jobs, MakeJobsEnv, nil
+	}
+
+	if quota, ok := CPUQuota(cgroupPath); ok {
+		jobs := int(math.Ceil(quota))
+		if jobs > cpus {
+			jobs = cpus
+		}
+		if jobs < 1 {
+			jobs = 1
+		}
+		return jobs, "cgroup CPU quota", nil
+	}
+
+	return cpus, "available CPUs", nil
+}
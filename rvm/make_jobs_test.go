@@ -0,0 +1,108 @@
+package rvm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testMakeJobs(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		cgroupPath string
+	)
+
+	writeFile := func(path, content string) {
+		path = filepath.Join(cgroupPath, path)
+		Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	it.Before(func() {
+		var err error
+		cgroupPath, err = ioutil.TempDir("", "cgroup")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(cgroupPath)).To(Succeed())
+	})
+
+	context("CPUQuota", func() {
+		it("reads the quota of cgroup v2", func() {
+			writeFile("cpu.max", "250000 100000\n")
+
+			quota, ok := rvm.CPUQuota(cgroupPath)
+			Expect(ok).To(BeTrue())
+			Expect(quota).To(Equal(2.5))
+		})
+
+		it("reads the quota of cgroup v1", func() {
+			writeFile("cpu,cpuacct/cpu.cfs_quota_us", "400000\n")
+			writeFile("cpu,cpuacct/cpu.cfs_period_us", "100000\n")
+
+			quota, ok := rvm.CPUQuota(cgroupPath)
+			Expect(ok).To(BeTrue())
+			Expect(quota).To(Equal(4.0))
+		})
+
+		it("returns false without a quota", func() {
+			writeFile("cpu.max", "max 100000\n")
+			_, ok := rvm.CPUQuota(cgroupPath)
+			Expect(ok).To(BeFalse())
+
+			Expect(os.Remove(filepath.Join(cgroupPath, "cpu.max"))).To(Succeed())
+			writeFile("cpu/cpu.cfs_quota_us", "-1\n")
+			writeFile("cpu/cpu.cfs_period_us", "100000\n")
+			_, ok = rvm.CPUQuota(cgroupPath)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	context("MakeJobs", func() {
+		it("rounds the CPU quota up", func() {
+			writeFile("cpu.max", "250000 100000\n")
+
+			jobs, source, err := rvm.MakeJobs("", cgroupPath, 16)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jobs).To(Equal(3))
+			Expect(source).To(Equal("cgroup CPU quota"))
+		})
+
+		it("limits the jobs to the available CPUs", func() {
+			writeFile("cpu.max", "3200000 100000\n")
+
+			jobs, _, err := rvm.MakeJobs("", cgroupPath, 16)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jobs).To(Equal(16))
+		})
+
+		it("uses the available CPUs without a quota", func() {
+			jobs, source, err := rvm.MakeJobs("", cgroupPath, 16)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jobs).To(Equal(16))
+			Expect(source).To(Equal("available CPUs"))
+		})
+
+		it("prefers BP_RVM_MAKE_JOBS", func() {
+			writeFile("cpu.max", "250000 100000\n")
+
+			jobs, source, err := rvm.MakeJobs("6", cgroupPath, 16)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jobs).To(Equal(6))
+			Expect(source).To(Equal("BP_RVM_MAKE_JOBS"))
+		})
+
+		it("returns an error for invalid values", func() {
+			_, _, err := rvm.MakeJobs("0", cgroupPath, 16)
+			Expect(err).To(MatchError("invalid value '0' for BP_RVM_MAKE_JOBS, expected a positive number"))
+		})
+	})
+}
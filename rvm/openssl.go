@@ -0,0 +1,161 @@
+package rvm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// OpenSSLLayerName is the name of the layer containing the private OpenSSL
+// used to compile Rubies that do not support the OpenSSL of the stack
+const OpenSSLLayerName = "openssl"
+
+// openSSLVersionRegEx matches the version in the header opensslv.h of
+// OpenSSL 1.x and 3.x
+var openSSLVersionRegEx = regexp.MustCompile(`OPENSSL_VERSION_TEXT\s+"OpenSSL ([0-9][0-9a-z.]*)`)
+
+// SystemOpenSSLVersion returns the version of the OpenSSL headers installed in
+// one of the given include directories or an empty string if there are none
+func SystemOpenSSLVersion(includeDirs []string) string {
+	for _, includeDir := range includeDirs {
+		matches, _ := filepath.Glob(filepath.Join(includeDir, "openssl", "opensslv.h"))
+		for _, match := range matches {
+			header, err := ioutil.ReadFile(match)
+			if err != nil {
+				continue
+			}
+			if version := openSSLVersionRegEx.FindSubmatch(header); version != nil {
+				return string(version[1])
+			}
+		}
+	}
+	return ""
+}
+
+// OpenSSLCompatible returns false if the given Ruby version cannot be
+// compiled against the given OpenSSL version. Rubies older than 3.1 do not
+// support OpenSSL 3, a partial version counts as its most recent release.
+func OpenSSLCompatible(rubyVersion, openSSLVersion string) bool {
+	engine, segments := splitRubyVersion(rubyVersion)
+	if engine != "ruby" || len(segments) == 0 {
+		return true
+	}
+
+	if !strings.HasPrefix(openSSLVersion, "3.") {
+		return true
+	}
+
+	version := strings.Join(segments, ".")
+	return compareVersionSegments(version, "3.1") >= 0 || strings.HasPrefix("3.1", version+".")
+}
+
+// OpenSSLOptions returns the option that makes Ruby use the OpenSSL in the
+// given layer
+func OpenSSLOptions(layerPath string) []string {
+	return []string{"--with-openssl-dir=" + layerPath}
+}
+
+// installOpenSSL compiles the configured OpenSSL version into the OpenSSL
+// layer unless the cached layer contains it already
+func (r Env) installOpenSSL(build, launch bool) (packit.Layer, error) {
+	openSSLLayer, err := r.Context.Layers.Get(OpenSSLLayerName)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	metadata := map[string]interface{}{
+		"openssl_version": r.Configuration.OpenSSLVersion,
+		"stack":           r.Target.Name(),
+		"arch":            r.Target.Arch,
+	}
+
+	cached := true
+	for key, value := range metadata {
+		if openSSLLayer.Metadata[key] != value {
+			cached = false
+		}
+	}
+
+	if cached {
+		r.Logger.Process("Reusing cached layer %s", openSSLLayer.Path)
+	} else {
+		if openSSLLayer, err = openSSLLayer.Reset(); err != nil {
+			r.Logger.Process("Resetting OpenSSL layer failed")
+			return packit.Layer{}, err
+		}
+
+		err = r.compileOpenSSL(openSSLLayer.Path)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+	}
+
+	// Rubies compiled against this OpenSSL load its libraries at launch
+	openSSLLayer.Build, openSSLLayer.Cache, openSSLLayer.Launch = build, true, launch
+	openSSLLayer.Metadata = metadata
+
+	return openSSLLayer, nil
+}
+
+// compileOpenSSL downloads, verifies and compiles OpenSSL, which uses the
+// certificates of the stack
+func (r Env) compileOpenSSL(layerPath string) error {
+	version := r.Configuration.OpenSSLVersion
+	uri := strings.ReplaceAll(r.Configuration.OpenSSLURI, "{version}", version)
+	r.Logger.Process("Compiling OpenSSL version '%s' from URI '%s'", version, uri)
+
+	sourcePath, err := ioutil.TempDir("", "openssl-src")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(sourcePath)
+
+	archivePath := filepath.Join(sourcePath, "openssl.tar.gz")
+	err = Download(uri, archivePath)
+	if err != nil {
+		return err
+	}
+
+	checksum, err := fileChecksum(archivePath)
+	if err != nil {
+		return err
+	}
+	if checksum != r.Configuration.OpenSSLSHA256 {
+		return fmt.Errorf("checksum of %s does not match, expected %s but got %s", uri, r.Configuration.OpenSSLSHA256, checksum)
+	}
+
+	opensslSourcePath := filepath.Join(sourcePath, "openssl")
+	err = Extract(archivePath, opensslSourcePath, 1)
+	if err != nil {
+		return err
+	}
+
+	compileCmd := strings.Join([]string{
+		"cd", opensslSourcePath,
+		"&&", "./config", "--prefix=" + layerPath, "--openssldir=" + filepath.Join(layerPath, "ssl"), "shared", "zlib",
+		"&&", "make", "-j", strconv.Itoa(r.makeJobs),
+		"&&", "make", "install_sw", "install_ssldirs",
+	}, " ")
+	err = r.runBashCmd(compileCmd, nil)
+	if err != nil {
+		return err
+	}
+
+	err = os.RemoveAll(filepath.Join(layerPath, "ssl", "certs"))
+	if err != nil {
+		return err
+	}
+
+	err = os.Symlink("/etc/ssl/certs", filepath.Join(layerPath, "ssl", "certs"))
+	if err != nil {
+		return err
+	}
+
+	return os.Symlink("/etc/ssl/certs/ca-certificates.crt", filepath.Join(layerPath, "ssl", "cert.pem"))
+}
@@ -0,0 +1,85 @@
+package rvm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testOpenSSL(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		includeDir string
+	)
+
+	writeHeader := func(path, content string) {
+		path = filepath.Join(includeDir, path)
+		Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	it.Before(func() {
+		var err error
+		includeDir, err = ioutil.TempDir("", "include")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(includeDir)).To(Succeed())
+	})
+
+	context("SystemOpenSSLVersion", func() {
+		it("reads the version of OpenSSL 3", func() {
+			writeHeader("x86_64-linux-gnu/openssl/opensslv.h", "# define OPENSSL_VERSION_TEXT \"OpenSSL 3.0.2 15 Mar 2022\"\n")
+
+			Expect(rvm.SystemOpenSSLVersion([]string{includeDir, filepath.Join(includeDir, "*-linux-gnu")})).To(Equal("3.0.2"))
+		})
+
+		it("reads the version of OpenSSL 1.1", func() {
+			writeHeader("openssl/opensslv.h", "# define OPENSSL_VERSION_TEXT  \"OpenSSL 1.1.1f  31 Mar 2020\"\n")
+
+			Expect(rvm.SystemOpenSSLVersion([]string{includeDir})).To(Equal("1.1.1f"))
+		})
+
+		it("returns an empty version if the headers are not installed", func() {
+			Expect(rvm.SystemOpenSSLVersion([]string{includeDir})).To(BeEmpty())
+		})
+	})
+
+	context("OpenSSLCompatible", func() {
+		it("does not compile Rubies older than 3.1 against OpenSSL 3", func() {
+			Expect(rvm.OpenSSLCompatible("2.7.8", "3.0.2")).To(BeFalse())
+			Expect(rvm.OpenSSLCompatible("ruby-3.0.6", "3.0.2")).To(BeFalse())
+			Expect(rvm.OpenSSLCompatible("3.0", "3.0.2")).To(BeFalse())
+			Expect(rvm.OpenSSLCompatible("3.1.4", "3.0.2")).To(BeTrue())
+			Expect(rvm.OpenSSLCompatible("3.3.0", "3.0.2")).To(BeTrue())
+		})
+
+		it("treats partial versions as their most recent release", func() {
+			Expect(rvm.OpenSSLCompatible("3", "3.0.2")).To(BeTrue())
+			Expect(rvm.OpenSSLCompatible("2", "3.0.2")).To(BeFalse())
+		})
+
+		it("compiles every Ruby against OpenSSL 1.1", func() {
+			Expect(rvm.OpenSSLCompatible("2.7.8", "1.1.1f")).To(BeTrue())
+			Expect(rvm.OpenSSLCompatible("2.7.8", "")).To(BeTrue())
+		})
+
+		it("ignores other Ruby engines", func() {
+			Expect(rvm.OpenSSLCompatible("jruby-9.4.0.0", "3.0.2")).To(BeTrue())
+		})
+	})
+
+	context("OpenSSLOptions", func() {
+		it("points Ruby to the OpenSSL layer", func() {
+			Expect(rvm.OpenSSLOptions("/layers/openssl")).To(Equal([]string{"--with-openssl-dir=/layers/openssl"}))
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package rvm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PatchesPathEnv is the name of the environment variable that sets the
+// directory of the app containing patches applied to Ruby before compiling
+const PatchesPathEnv = "BP_RVM_PATCHES_PATH"
+
+// DefaultPatchesPath is the directory of the app containing the patches
+// unless configured otherwise, relative to the project path
+const DefaultPatchesPath = ".rvm/patches"
+
+// PatchesPath returns the directory containing the patches of the app
+func PatchesPath(projectPath string) string {
+	path := DefaultPatchesPath
+	if value := os.Getenv(PatchesPathEnv); value != "" {
+		path = value
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(projectPath, path)
+}
+
+// RubyPatchesDir returns the subdirectory of the patches directory holding
+// the patches of the given Ruby version or an empty string if there is none.
+// The subdirectory must be named exactly like the Ruby version without the
+// "ruby-" prefix, so "ruby-2.7.6" uses the directory "2.7.6" and "2.7" the
+// directory "2.7", since the patches of one release may not apply to another.
+func RubyPatchesDir(patchesPath, rubyVersion string) (string, error) {
+	dir := filepath.Join(patchesPath, strings.TrimPrefix(rubyVersion, "ruby-"))
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", nil
+	}
+	return dir, nil
+}
+
+// RubyPatches returns the "*.patch" files in the subdirectory of the patches
+// directory matching the given Ruby version, e.g. ".rvm/patches/2.7.6",
+// sorted by name
+func RubyPatches(patchesPath, rubyVersion string) ([]string, error) {
+	dir, err := RubyPatchesDir(patchesPath, rubyVersion)
+	if err != nil || dir == "" {
+		return nil, err
+	}
+
+	patches, err := filepath.Glob(filepath.Join(dir, "*.patch"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(patches)
+	return patches, nil
+}
+
+// PatchesChecksum returns the checksums of the patches of all Ruby versions
+// in a stable order, so changing a patch invalidates the cached Rubies
+func PatchesChecksum(patches map[string][]string) (string, error) {
+	var rubyVersions []string
+	for rubyVersion := range patches {
+		rubyVersions = append(rubyVersions, rubyVersion)
+	}
+	sort.Strings(rubyVersions)
+
+	var checksums []string
+	for _, rubyVersion := range rubyVersions {
+		for _, patch := range patches[rubyVersion] {
+			checksum, err := fileChecksum(patch)
+			if err != nil {
+				return "", err
+			}
+			checksums = append(checksums, fmt.Sprintf("%s/%s:%s", rubyVersion, filepath.Base(patch), checksum))
+		}
+	}
+	return strings.Join(checksums, ","), nil
+}
@@ -0,0 +1,128 @@
+package rvm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPatches(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		projectPath string
+		patchesPath string
+	)
+
+	writePatch := func(path, content string) string {
+		path = filepath.Join(patchesPath, path)
+		Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	it.Before(func() {
+		var err error
+		projectPath, err = ioutil.TempDir("", "project")
+		Expect(err).NotTo(HaveOccurred())
+		patchesPath = filepath.Join(projectPath, ".rvm", "patches")
+	})
+
+	it.After(func() {
+		Expect(os.Unsetenv("BP_RVM_PATCHES_PATH")).To(Succeed())
+		Expect(os.RemoveAll(projectPath)).To(Succeed())
+	})
+
+	context("PatchesPath", func() {
+		it("defaults to .rvm/patches in the project path", func() {
+			Expect(rvm.PatchesPath(projectPath)).To(Equal(patchesPath))
+		})
+
+		it("reads BP_RVM_PATCHES_PATH relative to the project path", func() {
+			Expect(os.Setenv("BP_RVM_PATCHES_PATH", "config/patches")).To(Succeed())
+			Expect(rvm.PatchesPath(projectPath)).To(Equal(filepath.Join(projectPath, "config", "patches")))
+
+			Expect(os.Setenv("BP_RVM_PATCHES_PATH", "/patches")).To(Succeed())
+			Expect(rvm.PatchesPath(projectPath)).To(Equal("/patches"))
+		})
+	})
+
+	context("RubyPatches", func() {
+		it("returns the patches of the Ruby version sorted by name", func() {
+			second := writePatch("2.7.6/02-fix-cve.patch", "second")
+			first := writePatch("2.7.6/01-backport.patch", "first")
+			writePatch("2.7.6/README.md", "not a patch")
+			writePatch("3.1.2/01-other.patch", "other")
+
+			patches, err := rvm.RubyPatches(patchesPath, "2.7.6")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patches).To(Equal([]string{first, second}))
+		})
+
+		it("uses the directory named exactly like the Ruby version", func() {
+			patch := writePatch("3.1.2/01-fix.patch", "fix")
+			writePatch("3.1.1/01-old.patch", "old")
+
+			patches, err := rvm.RubyPatches(patchesPath, "ruby-3.1.2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patches).To(Equal([]string{patch}))
+
+			patches, err = rvm.RubyPatches(patchesPath, "3.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patches).To(BeEmpty())
+
+			partial := writePatch("3.1/01-partial.patch", "partial")
+			dir, err := rvm.RubyPatchesDir(patchesPath, "3.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dir).To(Equal(filepath.Join(patchesPath, "3.1")))
+			Expect(rvm.RubyPatches(patchesPath, "3.1")).To(Equal([]string{partial}))
+		})
+
+		it("returns no patches if there is no directory for the Ruby version", func() {
+			writePatch("3.1.2/01-fix.patch", "fix")
+
+			patches, err := rvm.RubyPatches(patchesPath, "3.2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patches).To(BeEmpty())
+
+			dir, err := rvm.RubyPatchesDir(patchesPath, "3.2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dir).To(BeEmpty())
+		})
+
+		it("returns no patches if the directory does not exist", func() {
+			patches, err := rvm.RubyPatches(patchesPath, "2.7.6")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patches).To(BeEmpty())
+		})
+	})
+
+	context("PatchesChecksum", func() {
+		it("is empty without patches", func() {
+			Expect(rvm.PatchesChecksum(map[string][]string{})).To(BeEmpty())
+		})
+
+		it("changes with the content of a patch", func() {
+			patch := writePatch("2.7.6/01-backport.patch", "first")
+			patches := map[string][]string{"2.7.6": {patch}}
+
+			checksum, err := rvm.PatchesChecksum(patches)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(checksum).To(HavePrefix("2.7.6/01-backport.patch:"))
+
+			writePatch("2.7.6/01-backport.patch", "changed")
+			Expect(rvm.PatchesChecksum(patches)).NotTo(Equal(checksum))
+		})
+
+		it("returns an error if a patch cannot be read", func() {
+			_, err := rvm.PatchesChecksum(map[string][]string{"2.7.6": {filepath.Join(patchesPath, "missing.patch")}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+}
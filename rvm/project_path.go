@@ -0,0 +1,73 @@
+package rvm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProjectPathEnv is the name of the environment variable that sets the
+// directory of the app relative to the working directory
+const ProjectPathEnv = "BP_RVM_PROJECT_PATH"
+
+// ProjectPath returns the absolute path of the directory that contains the
+// app's Gemfile and Ruby version sources. It defaults to the working
+// directory and can be changed for monorepos using the environment variable
+// BP_RVM_PROJECT_PATH or the key "project_path" in the buildpack.yml file
+// located in the working directory.
+func ProjectPath(workingDir string) (string, error) {
+	path, source := os.Getenv(ProjectPathEnv), ProjectPathEnv
+	if path == "" {
+		buildPackYMLPath := filepath.Join(workingDir, BuildpackYMLSource)
+		buildPackYML, err := BuildpackYMLParse(buildPackYMLPath)
+		if err != nil {
+			return "", err
+		}
+		path, source = buildPackYML.ProjectPath, buildPackYMLPath
+	}
+
+	if path == "" {
+		return workingDir, nil
+	}
+
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("project path '%s' set in %s must be relative to the working directory", path, source)
+	}
+
+	projectPath := filepath.Join(workingDir, path)
+	if !isWithin(workingDir, projectPath) {
+		return "", fmt.Errorf("project path '%s' set in %s is outside of the working directory", path, source)
+	}
+
+	info, err := os.Stat(projectPath)
+	if err != nil {
+		return "", fmt.Errorf("project path '%s' set in %s: %w", path, source, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("project path '%s' set in %s is not a directory", path, source)
+	}
+
+	// symlinks within the working directory must not lead outside of it either
+	resolvedWorkingDir, err := filepath.EvalSymlinks(workingDir)
+	if err != nil {
+		return "", err
+	}
+	resolvedProjectPath, err := filepath.EvalSymlinks(projectPath)
+	if err != nil {
+		return "", err
+	}
+	if !isWithin(resolvedWorkingDir, resolvedProjectPath) {
+		return "", fmt.Errorf("project path '%s' set in %s is outside of the working directory", path, source)
+	}
+
+	return projectPath, nil
+}
+
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,90 @@
+package rvm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testProjectPath(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = ioutil.TempDir("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(workingDir, "services", "api"), os.ModePerm)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.Unsetenv("BP_RVM_PROJECT_PATH")).To(Succeed())
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	it("defaults to the working directory", func() {
+		projectPath, err := rvm.ProjectPath(workingDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(projectPath).To(Equal(workingDir))
+	})
+
+	it("returns the project path set in buildpack.yml", func() {
+		err := ioutil.WriteFile(filepath.Join(workingDir, "buildpack.yml"), []byte("rvm:\n  project_path: services/api\n"), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		projectPath, err := rvm.ProjectPath(workingDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(projectPath).To(Equal(filepath.Join(workingDir, "services", "api")))
+	})
+
+	it("prefers the project path set by BP_RVM_PROJECT_PATH", func() {
+		err := ioutil.WriteFile(filepath.Join(workingDir, "buildpack.yml"), []byte("rvm:\n  project_path: services\n"), 0644)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Setenv("BP_RVM_PROJECT_PATH", "./services/api/")).To(Succeed())
+
+		projectPath, err := rvm.ProjectPath(workingDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(projectPath).To(Equal(filepath.Join(workingDir, "services", "api")))
+	})
+
+	context("failure cases", func() {
+		it("returns an error for paths outside of the working directory", func() {
+			Expect(os.Setenv("BP_RVM_PROJECT_PATH", "services/../../etc")).To(Succeed())
+
+			_, err := rvm.ProjectPath(workingDir)
+			Expect(err).To(MatchError("project path 'services/../../etc' set in BP_RVM_PROJECT_PATH is outside of the working directory"))
+		})
+
+		it("returns an error for symlinks pointing outside of the working directory", func() {
+			Expect(os.Symlink(os.TempDir(), filepath.Join(workingDir, "tmp"))).To(Succeed())
+			Expect(os.Setenv("BP_RVM_PROJECT_PATH", "tmp")).To(Succeed())
+
+			_, err := rvm.ProjectPath(workingDir)
+			Expect(err).To(MatchError("project path 'tmp' set in BP_RVM_PROJECT_PATH is outside of the working directory"))
+		})
+
+		it("returns an error for absolute paths", func() {
+			Expect(os.Setenv("BP_RVM_PROJECT_PATH", "/workspace/services/api")).To(Succeed())
+
+			_, err := rvm.ProjectPath(workingDir)
+			Expect(err).To(MatchError("project path '/workspace/services/api' set in BP_RVM_PROJECT_PATH must be relative to the working directory"))
+		})
+
+		it("returns an error if the project path does not exist", func() {
+			Expect(os.Setenv("BP_RVM_PROJECT_PATH", "services/web")).To(Succeed())
+
+			_, err := rvm.ProjectPath(workingDir)
+			Expect(err).To(MatchError(ContainSubstring("project path 'services/web' set in BP_RVM_PROJECT_PATH: stat")))
+		})
+	})
+}
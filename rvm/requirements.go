@@ -0,0 +1,113 @@
+package rvm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// ExtraRequiresEnv is the name of the environment variable that lists
+// companion dependencies required by the app, e.g. "node@18.*,yarn"
+const ExtraRequiresEnv = "BP_RVM_EXTRA_REQUIRES"
+
+// Requirement represents a companion dependency listed under the key
+// "requires" in buildpack.yml. Build and Launch default to true.
+type Requirement struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	Build   *bool  `yaml:"build"`
+	Launch  *bool  `yaml:"launch"`
+}
+
+// RequirementMetadata represents the metadata of a build plan requirement for
+// a companion dependency
+type RequirementMetadata struct {
+	Version       string `toml:"version,omitempty"`
+	VersionSource string `toml:"version-source,omitempty"`
+	Build         bool   `toml:"build"`
+	Launch        bool   `toml:"launch"`
+}
+
+// ExtraRequirements returns the build plan requirements for the companion
+// dependencies listed in buildpack.yml and BP_RVM_EXTRA_REQUIRES. Entries of
+// the environment variable replace entries of buildpack.yml with the same
+// name. The keys "require_node" and "node_version" of buildpack.yml are
+// supported as an alias for a requirement named "node".
+func ExtraRequirements(buildPackYML BuildPackYML, configuration Configuration) ([]packit.BuildPlanRequirement, error) {
+	var requirements []packit.BuildPlanRequirement
+
+	add := func(requirement packit.BuildPlanRequirement) {
+		for i := range requirements {
+			if requirements[i].Name == requirement.Name {
+				requirements[i] = requirement
+				return
+			}
+		}
+		requirements = append(requirements, requirement)
+	}
+
+	for _, requirement := range buildPackYML.Requires {
+		if requirement.Name == "" {
+			return nil, fmt.Errorf("a requirement in %s is missing a name", BuildpackYMLSource)
+		}
+		add(packit.BuildPlanRequirement{
+			Name: requirement.Name,
+			Metadata: RequirementMetadata{
+				Version:       requirement.Version,
+				VersionSource: BuildpackYMLSource,
+				Build:         requirement.Build == nil || *requirement.Build,
+				Launch:        requirement.Launch == nil || *requirement.Launch,
+			},
+		})
+	}
+
+	if (buildPackYML.RequireNode || configuration.DefaultRequireNode) && !hasRequirement(requirements, "node") {
+		nodeVersion := configuration.DefaultNodeVersion
+		if buildPackYML.NodeVersion != "" {
+			nodeVersion = buildPackYML.NodeVersion
+		}
+		add(packit.BuildPlanRequirement{
+			Name: "node",
+			Metadata: RequirementMetadata{
+				Version:       nodeVersion,
+				VersionSource: BuildpackYMLSource,
+				Build:         true,
+				Launch:        true,
+			},
+		})
+	}
+
+	for _, entry := range strings.Split(os.Getenv(ExtraRequiresEnv), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, version, _ := strings.Cut(entry, "@")
+		if name == "" {
+			return nil, fmt.Errorf("invalid requirement '%s' in %s, expected <name>[@<version>]", entry, ExtraRequiresEnv)
+		}
+		add(packit.BuildPlanRequirement{
+			Name: name,
+			Metadata: RequirementMetadata{
+				Version:       version,
+				VersionSource: ExtraRequiresEnv,
+				Build:         true,
+				Launch:        true,
+			},
+		})
+	}
+
+	return requirements, nil
+}
+
+func hasRequirement(requirements []packit.BuildPlanRequirement, name string) bool {
+	for _, requirement := range requirements {
+		if requirement.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package rvm_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRequirements(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		configuration rvm.Configuration
+	)
+
+	it.Before(func() {
+		configuration = rvm.Configuration{DefaultNodeVersion: "12.*"}
+	})
+
+	it.After(func() {
+		Expect(os.Unsetenv("BP_RVM_EXTRA_REQUIRES")).To(Succeed())
+	})
+
+	it("returns no requirements by default", func() {
+		requirements, err := rvm.ExtraRequirements(rvm.BuildPackYML{}, configuration)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requirements).To(BeEmpty())
+	})
+
+	it("returns the requirements listed in buildpack.yml", func() {
+		launch := false
+		requirements, err := rvm.ExtraRequirements(rvm.BuildPackYML{
+			Requires: []rvm.Requirement{
+				{Name: "node", Version: "18.*"},
+				{Name: "yarn", Launch: &launch},
+			},
+		}, configuration)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requirements).To(Equal([]packit.BuildPlanRequirement{
+			{
+				Name:     "node",
+				Metadata: rvm.RequirementMetadata{Version: "18.*", VersionSource: "buildpack.yml", Build: true, Launch: true},
+			},
+			{
+				Name:     "yarn",
+				Metadata: rvm.RequirementMetadata{VersionSource: "buildpack.yml", Build: true, Launch: false},
+			},
+		}))
+	})
+
+	it("supports require_node as an alias", func() {
+		requirements, err := rvm.ExtraRequirements(rvm.BuildPackYML{RequireNode: true}, configuration)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requirements).To(Equal([]packit.BuildPlanRequirement{
+			{
+				Name:     "node",
+				Metadata: rvm.RequirementMetadata{Version: "12.*", VersionSource: "buildpack.yml", Build: true, Launch: true},
+			},
+		}))
+	})
+
+	it("lets BP_RVM_EXTRA_REQUIRES replace requirements of the same name", func() {
+		Expect(os.Setenv("BP_RVM_EXTRA_REQUIRES", "node@18.*, yarn")).To(Succeed())
+
+		requirements, err := rvm.ExtraRequirements(rvm.BuildPackYML{RequireNode: true, NodeVersion: "16.*"}, configuration)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requirements).To(Equal([]packit.BuildPlanRequirement{
+			{
+				Name:     "node",
+				Metadata: rvm.RequirementMetadata{Version: "18.*", VersionSource: "BP_RVM_EXTRA_REQUIRES", Build: true, Launch: true},
+			},
+			{
+				Name:     "yarn",
+				Metadata: rvm.RequirementMetadata{VersionSource: "BP_RVM_EXTRA_REQUIRES", Build: true, Launch: true},
+			},
+		}))
+	})
+
+	context("failure cases", func() {
+		it("returns an error for requirements without a name", func() {
+			Expect(os.Setenv("BP_RVM_EXTRA_REQUIRES", "@18.*")).To(Succeed())
+
+			_, err := rvm.ExtraRequirements(rvm.BuildPackYML{}, configuration)
+			Expect(err).To(MatchError("invalid requirement '@18.*' in BP_RVM_EXTRA_REQUIRES, expected <name>[@<version>]"))
+		})
+	})
+}
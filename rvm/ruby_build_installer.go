@@ -0,0 +1,123 @@
+package rvm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// RubyBuildURI is the URI of the ruby-build release archives, the version is
+// inserted in place of %s
+const RubyBuildURI = "https://github.com/rbenv/ruby-build/archive/refs/tags/v%s.tar.gz"
+
+// RubyBuildInstaller installs Rubies using ruby-build
+type RubyBuildInstaller struct {
+	env Env
+}
+
+// NewRubyBuildInstaller returns a new RubyBuildInstaller
+func NewRubyBuildInstaller(env Env) RubyBuildInstaller {
+	return RubyBuildInstaller{
+		env: env,
+	}
+}
+
+// Name returns the name of the ruby-build backend
+func (i RubyBuildInstaller) Name() string {
+	return RubyBuildInstallerName
+}
+
+// Version returns the version of ruby-build to install
+func (i RubyBuildInstaller) Version() string {
+	return i.env.Configuration.RubyBuildVersion
+}
+
+// ManifestPatterns returns the patterns of the ruby-build executable
+func (i RubyBuildInstaller) ManifestPatterns() []string {
+	return []string{filepath.Join("bin", "ruby-build")}
+}
+
+// Setup downloads ruby-build into its layer
+func (i RubyBuildInstaller) Setup(layer *packit.Layer) error {
+	uri := fmt.Sprintf(RubyBuildURI, i.Version())
+	i.env.Logger.Process("Installing ruby-build version '%s' from URI '%s'", i.Version(), uri)
+
+	return downloadAndExtract(uri, layer.Path)
+}
+
+// InstallRuby installs the most recent Ruby version matching the given
+// version, the options are passed to the configure script of Ruby
+func (i RubyBuildInstaller) InstallRuby(rubyVersion string, options []string, patches []string, layer *packit.Layer) error {
+	rubyBuild := filepath.Join(layer.Path, "bin", "ruby-build")
+
+	definitions, err := commandOutput(rubyBuild, "--definitions")
+	if err != nil {
+		i.env.Logger.Detail("Listing the Ruby versions available to ruby-build failed")
+		return err
+	}
+
+	definition, err := ResolveRubyDefinition(definitions, rubyVersion)
+	if err != nil {
+		return err
+	}
+
+	ruby := definition
+	if strings.ContainsAny(ruby[:1], "0123456789") {
+		ruby = "ruby-" + ruby
+	}
+
+	err = os.MkdirAll(filepath.Join(i.env.rubyLayerPath, "gems", ruby), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	// every Ruby is built in its own directory, so Rubies can be compiled in
+	// parallel
+	buildPath, err := ioutil.TempDir("", "ruby-build-"+definition)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(buildPath)
+
+	prefix := filepath.Join(i.env.rubyLayerPath, "rubies", ruby)
+	rubyBuildCmd := strings.Join([]string{rubyBuild, definition, prefix}, " ")
+	if len(patches) > 0 {
+		// ruby-build reads a single patch from stdin
+		rubyBuildCmd = strings.Join(append(append([]string{"cat"}, patches...), "|", rubyBuild, "--patch", definition, prefix), " ")
+	}
+	return i.env.runBashCmd(rubyBuildCmd, []string{
+		"RUBY_BUILD_CACHE_PATH=" + i.env.archivesLayerPath,
+		"RUBY_BUILD_BUILD_PATH=" + buildPath,
+		"RUBY_CONFIGURE_OPTS=" + strings.Join(options, " "),
+		"MAKE_OPTS=-j " + strconv.Itoa(i.env.makeJobs),
+	})
+}
+
+// SourceArchive returns the name of the source archive ruby-build downloads
+func (i RubyBuildInstaller) SourceArchive(rubyVersion string) string {
+	return RubySourceArchive(rubyVersion, "tar.gz")
+}
+
+// RemoveRuby removes an installed Ruby version from the Ruby layer
+func (i RubyBuildInstaller) RemoveRuby(rubyVersion string, layer *packit.Layer) error {
+	return removeInstalledRuby(i.env.rubyLayerPath, rubyVersion)
+}
+
+// RunRubyCmd runs a command in the environment of an installed Ruby
+func (i RubyBuildInstaller) RunRubyCmd(rubyVersion, command string, layer *packit.Layer) error {
+	environment, err := rubyLayerEnvironment(i.env.rubyLayerPath, rubyVersion)
+	if err != nil {
+		return err
+	}
+	return i.env.runBashCmd(command, environment)
+}
+
+// Finalize does nothing, ruby-build removes its build directories itself
+func (i RubyBuildInstaller) Finalize(defaultRubyVersion string, layer *packit.Layer) error {
+	return nil
+}
@@ -0,0 +1,137 @@
+package rvm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// RubyInstallURI is the URI of the ruby-install release archives, the
+// version is inserted in place of both %s
+const RubyInstallURI = "https://github.com/postmodern/ruby-install/releases/download/v%[1]s/ruby-install-%[1]s.tar.gz"
+
+// RubyInstallInstaller installs Rubies using ruby-install
+type RubyInstallInstaller struct {
+	env Env
+}
+
+// NewRubyInstallInstaller returns a new RubyInstallInstaller
+func NewRubyInstallInstaller(env Env) RubyInstallInstaller {
+	return RubyInstallInstaller{
+		env: env,
+	}
+}
+
+// Name returns the name of the ruby-install backend
+func (i RubyInstallInstaller) Name() string {
+	return RubyInstallInstallerName
+}
+
+// Version returns the version of ruby-install to install
+func (i RubyInstallInstaller) Version() string {
+	return i.env.Configuration.RubyInstallVersion
+}
+
+// ManifestPatterns returns the patterns of the ruby-install executable
+func (i RubyInstallInstaller) ManifestPatterns() []string {
+	return []string{filepath.Join("bin", "ruby-install")}
+}
+
+// Setup downloads ruby-install into its layer
+func (i RubyInstallInstaller) Setup(layer *packit.Layer) error {
+	uri := fmt.Sprintf(RubyInstallURI, i.Version())
+	i.env.Logger.Process("Installing ruby-install version '%s' from URI '%s'", i.Version(), uri)
+
+	return downloadAndExtract(uri, layer.Path)
+}
+
+// InstallRuby installs the most recent Ruby version matching the given
+// version, the options are passed to the configure script of Ruby
+func (i RubyInstallInstaller) InstallRuby(rubyVersion string, options []string, patches []string, layer *packit.Layer) error {
+	engine, version := RubyEngineVersion(rubyVersion)
+
+	// ruby-install extracts and builds Ruby in its source directory, so every
+	// Ruby gets its own one to allow compiling Rubies in parallel. A cached
+	// source archive is linked into it, an archive downloaded by ruby-install
+	// is moved into the archives layer afterwards.
+	srcDir, err := ioutil.TempDir("", "ruby-install-"+rubyVersion)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(srcDir)
+
+	if archive := i.SourceArchive(rubyVersion); archive != "" && fileExists(filepath.Join(i.env.archivesLayerPath, archive)) {
+		err = os.Symlink(filepath.Join(i.env.archivesLayerPath, archive), filepath.Join(srcDir, archive))
+		if err != nil {
+			return err
+		}
+	}
+
+	rubyInstallCmd := strings.Join([]string{
+		filepath.Join(layer.Path, "bin", "ruby-install"),
+		"--no-install-deps",
+		"--no-reinstall",
+		"--jobs",
+		strconv.Itoa(i.env.makeJobs),
+		"--rubies-dir",
+		filepath.Join(i.env.rubyLayerPath, "rubies"),
+		"--src-dir",
+		srcDir,
+	}, " ")
+	for _, patch := range patches {
+		rubyInstallCmd = strings.Join([]string{rubyInstallCmd, "--patch", patch}, " ")
+	}
+	rubyInstallCmd = strings.Join([]string{rubyInstallCmd, engine, version}, " ")
+	if len(options) > 0 {
+		rubyInstallCmd = strings.Join(append([]string{rubyInstallCmd, "--"}, options...), " ")
+	}
+
+	err = i.env.runBashCmd(rubyInstallCmd, nil)
+	if err != nil {
+		return err
+	}
+
+	stored, err := StoreArchives(srcDir, i.env.archivesLayerPath)
+	if err != nil {
+		return err
+	}
+	for _, archive := range stored {
+		i.env.Logger.Process("Cached source archive '%s'", archive)
+	}
+
+	ruby, err := findInstalledRuby(filepath.Join(i.env.rubyLayerPath, "rubies"), rubyVersion)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(filepath.Join(i.env.rubyLayerPath, "gems", ruby), os.ModePerm)
+}
+
+// SourceArchive returns the name of the source archive ruby-install downloads
+func (i RubyInstallInstaller) SourceArchive(rubyVersion string) string {
+	return RubySourceArchive(rubyVersion, "tar.xz")
+}
+
+// RemoveRuby removes an installed Ruby version from the Ruby layer
+func (i RubyInstallInstaller) RemoveRuby(rubyVersion string, layer *packit.Layer) error {
+	return removeInstalledRuby(i.env.rubyLayerPath, rubyVersion)
+}
+
+// RunRubyCmd runs a command in the environment of an installed Ruby
+func (i RubyInstallInstaller) RunRubyCmd(rubyVersion, command string, layer *packit.Layer) error {
+	environment, err := rubyLayerEnvironment(i.env.rubyLayerPath, rubyVersion)
+	if err != nil {
+		return err
+	}
+	return i.env.runBashCmd(command, environment)
+}
+
+// Finalize does nothing, since ruby-install builds every Ruby in a temporary
+// source directory
+func (i RubyInstallInstaller) Finalize(defaultRubyVersion string, layer *packit.Layer) error {
+	return nil
+}
@@ -0,0 +1,165 @@
+package rvm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// RubyInstallerEnv is the name of the environment variable that selects the
+// backend used to install Ruby
+const RubyInstallerEnv = "BP_RUBY_INSTALLER"
+
+// Names of the supported Ruby installer backends
+const (
+	RVMInstallerName         = "rvm"
+	RubyBuildInstallerName   = "ruby-build"
+	RubyInstallInstallerName = "ruby-install"
+)
+
+// RubyInstallerNames lists the names of the supported Ruby installer backends
+var RubyInstallerNames = []string{
+	RVMInstallerName,
+	RubyBuildInstallerName,
+	RubyInstallInstallerName,
+}
+
+// RubyInstaller represents a backend that installs Rubies. Every backend
+// installs itself into a layer named like the backend and the Rubies into
+// the directories "rubies/<ruby>" and "gems/<ruby>" of the Ruby layer, so
+// the environment of the Ruby layer does not depend on the backend.
+type RubyInstaller interface {
+	// Name returns the name of the backend, which is also the name of the
+	// layer the backend is installed into
+	Name() string
+	// Version returns the version of the backend
+	Version() string
+	// ManifestPatterns returns the patterns of the files verified before a
+	// cached layer of the backend is reused
+	ManifestPatterns() []string
+	// Setup installs the backend into its reset layer
+	Setup(layer *packit.Layer) error
+	// InstallRuby installs a Ruby version passing additional options to the
+	// build of Ruby and applying the given patch files to its sources
+	InstallRuby(rubyVersion string, options []string, patches []string, layer *packit.Layer) error
+	// SourceArchive returns the name the backend looks up the source archive
+	// of a Ruby version by in the archives layer, or an empty string if it is
+	// not known before installing
+	SourceArchive(rubyVersion string) string
+	// RemoveRuby removes a previously installed Ruby version
+	RemoveRuby(rubyVersion string, layer *packit.Layer) error
+	// RunRubyCmd runs a command in the environment of a Ruby version
+	RunRubyCmd(rubyVersion, command string, layer *packit.Layer) error
+	// Finalize removes temporary files after installing and selects the
+	// default Ruby version
+	Finalize(defaultRubyVersion string, layer *packit.Layer) error
+}
+
+// NewRubyInstaller returns the Ruby installer backend with the given name
+func NewRubyInstaller(name string, env Env) (RubyInstaller, error) {
+	switch name {
+	case RVMInstallerName:
+		return NewRVMInstaller(env), nil
+	case RubyBuildInstallerName:
+		return NewRubyBuildInstaller(env), nil
+	case RubyInstallInstallerName:
+		return NewRubyInstallInstaller(env), nil
+	}
+	return nil, fmt.Errorf("unknown Ruby installer '%s', supported installers are: %s", name, strings.Join(RubyInstallerNames, ", "))
+}
+
+// rubyLayerEnvironment returns the environment variables to run a command
+// using a Ruby installed into the Ruby layer without RVM
+func rubyLayerEnvironment(rubyLayerPath, rubyVersion string) ([]string, error) {
+	paths := RVMPaths{
+		Rubies: filepath.Join(rubyLayerPath, "rubies"),
+		Gems:   filepath.Join(rubyLayerPath, "gems"),
+	}
+
+	environment, err := LaunchEnvironment(paths, rubyVersion, os.Getenv("PATH"))
+	if err != nil {
+		return nil, err
+	}
+
+	var variables []string
+	for name, value := range environment {
+		variables = append(variables, name+"="+value)
+	}
+	return variables, nil
+}
+
+// removeInstalledRuby removes a Ruby installed into the Ruby layer and its
+// gems
+func removeInstalledRuby(rubyLayerPath, rubyVersion string) error {
+	ruby, err := findInstalledRuby(filepath.Join(rubyLayerPath, "rubies"), rubyVersion)
+	if err != nil {
+		return err
+	}
+
+	gemDirs, err := filepath.Glob(filepath.Join(rubyLayerPath, "gems", ruby+"@*"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range append([]string{filepath.Join(rubyLayerPath, "rubies", ruby), filepath.Join(rubyLayerPath, "gems", ruby)}, gemDirs...) {
+		err = os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveRubyDefinition returns the most recent of the given Ruby
+// definitions matching a possibly partial Ruby version, e.g. "3.1.4" for
+// "3.1" given the definitions "3.1.2" and "3.1.4". MRI versions may be
+// written the RVM way, e.g. "ruby-3.1".
+func ResolveRubyDefinition(definitions []string, rubyVersion string) (string, error) {
+	version := strings.TrimPrefix(rubyVersion, "ruby-")
+
+	var resolved []string
+	for _, definition := range definitions {
+		if definition == version || strings.HasPrefix(definition, version+".") {
+			if !strings.ContainsAny(definition, "-") || strings.Contains(version, "-") {
+				resolved = append(resolved, definition)
+			}
+		}
+	}
+
+	if len(resolved) == 0 {
+		return "", fmt.Errorf("Ruby version '%s' is not available", rubyVersion)
+	}
+
+	best := resolved[0]
+	for _, definition := range resolved[1:] {
+		if compareVersionSegments(definition, best) > 0 {
+			best = definition
+		}
+	}
+	return best, nil
+}
+
+// RubyEngineVersion splits a Ruby version into the engine and its version the
+// way ruby-install expects them, e.g. "jruby" and "9.3.4.0" for
+// "jruby-9.3.4.0", or "ruby" and "3.1.2" for both "3.1.2" and "ruby-3.1.2"
+func RubyEngineVersion(rubyVersion string) (string, string) {
+	if strings.ContainsAny(rubyVersion[:1], "0123456789") {
+		return "ruby", rubyVersion
+	}
+	engine, version, _ := strings.Cut(rubyVersion, "-")
+	return engine, version
+}
+
+// commandOutput runs a command and returns the whitespace separated words of
+// its output
+func commandOutput(name string, args ...string) ([]string, error) {
+	output, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(output)), nil
+}
@@ -0,0 +1,136 @@
+package rvm_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRubyInstaller(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		env rvm.Env
+	)
+
+	it.Before(func() {
+		env = rvm.Env{
+			Logger: rvm.NewLogEmitter(os.Stdout),
+			Configuration: rvm.Configuration{
+				DefaultRVMVersion:  "1.29.12",
+				RubyBuildVersion:   "20231225",
+				RubyInstallVersion: "0.9.3",
+			},
+		}
+	})
+
+	context("NewRubyInstaller", func() {
+		it("returns the RVM installer", func() {
+			installer, err := rvm.NewRubyInstaller("rvm", env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(installer.Name()).To(Equal("rvm"))
+			Expect(installer.Version()).To(Equal("1.29.12"))
+			Expect(installer.ManifestPatterns()).To(Equal(rvm.RVMLayerManifestPatterns))
+		})
+
+		it("returns the ruby-build installer", func() {
+			installer, err := rvm.NewRubyInstaller("ruby-build", env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(installer.Name()).To(Equal("ruby-build"))
+			Expect(installer.Version()).To(Equal("20231225"))
+			Expect(installer.ManifestPatterns()).To(Equal([]string{"bin/ruby-build"}))
+		})
+
+		it("returns the ruby-install installer", func() {
+			installer, err := rvm.NewRubyInstaller("ruby-install", env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(installer.Name()).To(Equal("ruby-install"))
+			Expect(installer.Version()).To(Equal("0.9.3"))
+			Expect(installer.ManifestPatterns()).To(Equal([]string{"bin/ruby-install"}))
+		})
+
+		it("returns an error for unknown installers", func() {
+			_, err := rvm.NewRubyInstaller("chruby", env)
+			Expect(err).To(MatchError("unknown Ruby installer 'chruby', supported installers are: rvm, ruby-build, ruby-install"))
+		})
+	})
+
+	context("ResolveRubyDefinition", func() {
+		definitions := []string{"2.7.6", "3.1.2", "3.1.4", "3.2.0-preview1", "jruby-9.3.4.0"}
+
+		it("returns the most recent definition matching the version", func() {
+			Expect(rvm.ResolveRubyDefinition(definitions, "3.1.2")).To(Equal("3.1.2"))
+			Expect(rvm.ResolveRubyDefinition(definitions, "3.1")).To(Equal("3.1.4"))
+			Expect(rvm.ResolveRubyDefinition(definitions, "jruby-9.3.4.0")).To(Equal("jruby-9.3.4.0"))
+		})
+
+		it("understands MRI versions written the RVM way for ruby-build", func() {
+			Expect(rvm.ResolveRubyDefinition(definitions, "ruby-3.1.2")).To(Equal("3.1.2"))
+			Expect(rvm.ResolveRubyDefinition(definitions, "ruby-3.1")).To(Equal("3.1.4"))
+		})
+
+		it("returns an error for unavailable versions", func() {
+			_, err := rvm.ResolveRubyDefinition(definitions, "ruby-3.3")
+			Expect(err).To(MatchError("Ruby version 'ruby-3.3' is not available"))
+		})
+	})
+
+	context("RubyEngineVersion", func() {
+		it("understands MRI versions written the RVM way for ruby-install", func() {
+			engine, version := rvm.RubyEngineVersion("ruby-3.1.2")
+			Expect(engine).To(Equal("ruby"))
+			Expect(version).To(Equal("3.1.2"))
+		})
+
+		it("splits plain versions and other engines", func() {
+			engine, version := rvm.RubyEngineVersion("3.1")
+			Expect(engine).To(Equal("ruby"))
+			Expect(version).To(Equal("3.1"))
+
+			engine, version = rvm.RubyEngineVersion("jruby-9.3.4.0")
+			Expect(engine).To(Equal("jruby"))
+			Expect(version).To(Equal("9.3.4.0"))
+		})
+	})
+
+	context("SourceArchive", func() {
+		it("returns the source archive of MRI versions written the RVM way", func() {
+			rubyBuild, err := rvm.NewRubyInstaller("ruby-build", env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rubyBuild.SourceArchive("ruby-3.1.2")).To(Equal("ruby-3.1.2.tar.gz"))
+
+			rubyInstall, err := rvm.NewRubyInstaller("ruby-install", env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rubyInstall.SourceArchive("ruby-3.1.2")).To(Equal("ruby-3.1.2.tar.xz"))
+		})
+	})
+
+	context("InstallerName", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("BP_RUBY_INSTALLER")).To(Succeed())
+		})
+
+		it("defaults to RVM", func() {
+			Expect(rvm.Configuration{}.InstallerName()).To(Equal("rvm"))
+		})
+
+		it("returns the installer from buildpack.toml", func() {
+			Expect(rvm.Configuration{Installer: "ruby-build"}.InstallerName()).To(Equal("ruby-build"))
+		})
+
+		it("prefers the environment variable", func() {
+			Expect(os.Setenv("BP_RUBY_INSTALLER", "ruby-install")).To(Succeed())
+			Expect(rvm.Configuration{Installer: "ruby-build"}.InstallerName()).To(Equal("ruby-install"))
+		})
+
+		it("returns an error for unknown installers", func() {
+			Expect(os.Setenv("BP_RUBY_INSTALLER", "rbenv")).To(Succeed())
+			_, err := rvm.Configuration{}.InstallerName()
+			Expect(err).To(MatchError("unknown Ruby installer 'rbenv', supported installers are: rvm, ruby-build, ruby-install"))
+		})
+	})
+}
@@ -0,0 +1,90 @@
+package rvm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RubyLayerName is the name of the layer that contains the installed Rubies
+// and gems and is exported into the app image
+const RubyLayerName = "ruby"
+
+// StripStaticLibsEnv is the name of the environment variable that enables
+// removing static libraries from the Ruby layer
+const StripStaticLibsEnv = "BP_RVM_STRIP_STATIC_LIBS"
+
+// rubyLayerPrunePatterns match files in the Ruby layer that are not needed
+// to run Ruby
+var rubyLayerPrunePatterns = []string{
+	filepath.Join("rubies", "*", "share", "man"),
+	filepath.Join("rubies", "*", "share", "ri"),
+	filepath.Join("rubies", "*", "share", "doc"),
+	filepath.Join("rubies", "*", "lib", "ruby", "gems", "*", "doc"),
+	filepath.Join("gems", "*", "doc"),
+}
+
+// staticLibPatterns match static libraries in the Ruby layer, which are only
+// needed to link native extensions against a static libruby
+var staticLibPatterns = []string{
+	filepath.Join("rubies", "*", "lib", "*.a"),
+}
+
+// PruneRubyLayer removes documentation and optionally static libraries from
+// the Ruby layer and returns the number of bytes saved
+func PruneRubyLayer(path string, stripStaticLibs bool) (int64, error) {
+	patterns := rubyLayerPrunePatterns
+	if stripStaticLibs {
+		patterns = append(append([]string{}, patterns...), staticLibPatterns...)
+	}
+
+	var saved int64
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return 0, err
+		}
+
+		for _, match := range matches {
+			size, err := diskUsage(match)
+			if err != nil {
+				return 0, err
+			}
+
+			err = os.RemoveAll(match)
+			if err != nil {
+				return 0, err
+			}
+			saved += size
+		}
+	}
+
+	return saved, nil
+}
+
+// diskUsage returns the size of a file or the total size of all files within
+// a directory
+func diskUsage(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// formatSize returns a human readable representation of a size in bytes
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%d B", size)
+}
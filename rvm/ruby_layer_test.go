@@ -0,0 +1,77 @@
+package rvm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRubyLayer(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		layerPath string
+	)
+
+	writeFile := func(path string, size int) {
+		path = filepath.Join(layerPath, path)
+		Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(path, make([]byte, size), 0644)).To(Succeed())
+	}
+
+	it.Before(func() {
+		var err error
+		layerPath, err = ioutil.TempDir("", "ruby")
+		Expect(err).NotTo(HaveOccurred())
+
+		writeFile("rubies/ruby-3.1.2/bin/ruby", 100)
+		writeFile("rubies/ruby-3.1.2/lib/libruby.so", 100)
+		writeFile("rubies/ruby-3.1.2/lib/libruby-static.a", 300)
+		writeFile("rubies/ruby-3.1.2/share/man/man1/ruby.1", 10)
+		writeFile("rubies/ruby-3.1.2/share/ri/3.1.0/system/String/cdesc-String.ri", 20)
+		writeFile("rubies/ruby-3.1.2/lib/ruby/gems/3.1.0/doc/rake-13.0.6/ri/Rake.ri", 30)
+		writeFile("gems/ruby-3.1.2/doc/puma-5.6.4/ri/Puma.ri", 40)
+		writeFile("gems/ruby-3.1.2/gems/puma-5.6.4/lib/puma.rb", 50)
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(layerPath)).To(Succeed())
+	})
+
+	it("removes the documentation and returns the size saved", func() {
+		saved, err := rvm.PruneRubyLayer(layerPath, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(saved).To(Equal(int64(100)))
+
+		Expect(filepath.Join(layerPath, "rubies/ruby-3.1.2/share/man")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(layerPath, "rubies/ruby-3.1.2/share/ri")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(layerPath, "rubies/ruby-3.1.2/lib/ruby/gems/3.1.0/doc")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(layerPath, "gems/ruby-3.1.2/doc")).NotTo(BeAnExistingFile())
+
+		Expect(filepath.Join(layerPath, "rubies/ruby-3.1.2/bin/ruby")).To(BeAnExistingFile())
+		Expect(filepath.Join(layerPath, "rubies/ruby-3.1.2/lib/libruby.so")).To(BeAnExistingFile())
+		Expect(filepath.Join(layerPath, "rubies/ruby-3.1.2/lib/libruby-static.a")).To(BeAnExistingFile())
+		Expect(filepath.Join(layerPath, "gems/ruby-3.1.2/gems/puma-5.6.4/lib/puma.rb")).To(BeAnExistingFile())
+	})
+
+	it("removes static libraries if requested", func() {
+		saved, err := rvm.PruneRubyLayer(layerPath, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(saved).To(Equal(int64(400)))
+
+		Expect(filepath.Join(layerPath, "rubies/ruby-3.1.2/lib/libruby-static.a")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(layerPath, "rubies/ruby-3.1.2/lib/libruby.so")).To(BeAnExistingFile())
+	})
+
+	it("does nothing if there is nothing to prune", func() {
+		saved, err := rvm.PruneRubyLayer(filepath.Join(layerPath, "empty"), true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(saved).To(BeZero())
+	})
+}
@@ -0,0 +1,40 @@
+package rvm
+
+import (
+	"os"
+	"strings"
+)
+
+// RubyVersions returns the primary Ruby version followed by the additional
+// Ruby versions requested in buildpack.yml or BP_RVM_ADDITIONAL_RUBIES, which
+// takes precedence. Duplicates and repetitions of the primary version are
+// dropped.
+func RubyVersions(primaryRubyVersion string, buildPackYML BuildPackYML) []string {
+	rubyVersions := []string{primaryRubyVersion}
+
+	additionalRubyVersions := buildPackYML.AdditionalRubyVersions
+	if value := os.Getenv(AdditionalRubiesEnv); value != "" {
+		additionalRubyVersions = strings.Split(value, ",")
+	}
+
+	for _, rubyVersion := range additionalRubyVersions {
+		rubyVersion = strings.TrimSpace(rubyVersion)
+		if rubyVersion != "" && !contains(rubyVersions, rubyVersion) {
+			rubyVersions = append(rubyVersions, rubyVersion)
+		}
+	}
+
+	return rubyVersions
+}
+
+// RemovedRubyVersions returns the installed Ruby versions, e.g. the ones in a
+// cached layer, that are no longer requested
+func RemovedRubyVersions(installedRubyVersions, rubyVersions []string) []string {
+	var removed []string
+	for _, rubyVersion := range installedRubyVersions {
+		if !contains(rubyVersions, rubyVersion) {
+			removed = append(removed, rubyVersion)
+		}
+	}
+	return removed
+}
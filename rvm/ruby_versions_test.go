@@ -0,0 +1,56 @@
+package rvm_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRubyVersions(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("RubyVersions", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("BP_RVM_ADDITIONAL_RUBIES")).To(Succeed())
+		})
+
+		it("returns the primary version followed by the additional versions in buildpack.yml", func() {
+			rubyVersions := rvm.RubyVersions("3.1.2", rvm.BuildPackYML{AdditionalRubyVersions: []string{"2.7.6", "3.0.4"}})
+			Expect(rubyVersions).To(Equal([]string{"3.1.2", "2.7.6", "3.0.4"}))
+		})
+
+		it("prefers BP_RVM_ADDITIONAL_RUBIES over buildpack.yml", func() {
+			Expect(os.Setenv("BP_RVM_ADDITIONAL_RUBIES", " 3.2.2, 2.6.10 ")).To(Succeed())
+
+			rubyVersions := rvm.RubyVersions("3.1.2", rvm.BuildPackYML{AdditionalRubyVersions: []string{"2.7.6"}})
+			Expect(rubyVersions).To(Equal([]string{"3.1.2", "3.2.2", "2.6.10"}))
+		})
+
+		it("drops duplicates, empty entries and the primary version", func() {
+			Expect(os.Setenv("BP_RVM_ADDITIONAL_RUBIES", "2.7.6,,3.1.2, 2.7.6")).To(Succeed())
+
+			rubyVersions := rvm.RubyVersions("3.1.2", rvm.BuildPackYML{})
+			Expect(rubyVersions).To(Equal([]string{"3.1.2", "2.7.6"}))
+		})
+
+		it("returns only the primary version if no additional versions are requested", func() {
+			Expect(rvm.RubyVersions("3.1.2", rvm.BuildPackYML{})).To(Equal([]string{"3.1.2"}))
+		})
+	})
+
+	context("RemovedRubyVersions", func() {
+		it("returns the cached Ruby versions that are no longer requested", func() {
+			removed := rvm.RemovedRubyVersions([]string{"3.1.2", "2.7.6", "3.0.4"}, []string{"3.1.2", "3.2.2"})
+			Expect(removed).To(Equal([]string{"2.7.6", "3.0.4"}))
+		})
+
+		it("returns nothing if all cached Ruby versions are still requested", func() {
+			Expect(rvm.RemovedRubyVersions([]string{"3.1.2"}, []string{"3.1.2", "2.7.6"})).To(BeEmpty())
+			Expect(rvm.RemovedRubyVersions(nil, []string{"3.1.2"})).To(BeEmpty())
+		})
+	})
+}
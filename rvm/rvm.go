@@ -8,7 +8,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/paketo-buildpacks/packit/v2"
 )
@@ -20,30 +22,83 @@ type Env struct {
 	Logger        LogEmitter
 	Configuration Configuration
 	Environment   EnvironmentConfiguration
+	ProjectPath   string
+	Target        Target
+
+	rubyLayerPath     string
+	archivesLayerPath string
+	makeJobs          int
 }
 
 // BuildRvm builds the RVM environment
 func (r Env) BuildRvm() (packit.BuildResult, error) {
 	r.Logger.Title("%s %s", r.Context.BuildpackInfo.Name, r.Context.BuildpackInfo.Version)
 
+	r.Logger.Process("Target: %s\n", r.Target)
+	if r.Target.Arch != runtime.GOARCH {
+		r.Logger.Process("Warning: this buildpack was built for %s but the target architecture is %s\n", runtime.GOARCH, r.Target.Arch)
+	}
 	r.Logger.Process("Using RVM URI: %s\n", r.Configuration.URI)
 	r.Logger.Process("RVM version: %s\n", r.rvmVersion())
 	r.Logger.Process("build plan Ruby version: %s\n", r.rubyVersion())
 
-	buildResult, err := r.installRVM()
+	buildResult, err := r.installRubies()
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	if _, launch := r.layerFlags(); !launch {
+		r.Logger.Process("Ruby is not required at launch")
+		r.Logger.Break()
+		return buildResult, nil
+	}
+
+	processes, err := LaunchProcesses(r.ProjectPath)
 	if err != nil {
 		return packit.BuildResult{}, err
 	}
+
+	if len(processes) > 0 {
+		r.Logger.Process("Assigning launch processes:")
+		for _, process := range processes {
+			if r.ProjectPath != r.Context.WorkingDir {
+				process.WorkingDirectory = r.ProjectPath
+			}
+			// the command is the last argument passed to bash
+			r.Logger.Subprocess("%s: %s", process.Type, process.Args[len(process.Args)-1])
+			buildResult.Launch.Processes = append(buildResult.Launch.Processes, process)
+		}
+		r.Logger.Break()
+	}
+
 	return buildResult, nil
 }
 
+// bufferedEnv returns a copy of the environment whose log output is written
+// to the returned buffer instead
+func (r Env) bufferedEnv() (Env, *bytes.Buffer) {
+	output := bytes.NewBuffer(nil)
+	r.Logger = NewLogEmitter(output)
+	return r, output
+}
+
 // RunBashCmd executes a command using BASH
 func (r Env) RunBashCmd(command string, rvmLayer *packit.Layer) error {
+	variables := DefaultVariables(rvmLayer)
+	if r.rubyLayerPath != "" {
+		variables = append(variables, RubyLayerVariables(r.rubyLayerPath)...)
+	}
+	if r.archivesLayerPath != "" {
+		variables = append(variables, ArchivesLayerVariables(r.archivesLayerPath)...)
+	}
+	return r.runBashCmd(command, variables)
+}
+
+// runBashCmd executes a command using BASH with additional environment
+// variables
+func (r Env) runBashCmd(command string, variables []string) error {
 	cmd := exec.Command("bash", "-c", command)
-	cmd.Env = append(
-		os.Environ(),
-		DefaultVariables(rvmLayer)...,
-	)
+	cmd.Env = append(os.Environ(), variables...)
 
 	r.Logger.Process("Executing: %s", strings.Join(cmd.Args, " "))
 
@@ -111,140 +166,489 @@ func (r Env) rvmVersion() string {
 			rvmVersion = fmt.Sprintf("%v", entry.Metadata["rvm_version"])
 		}
 	}
-	cmp := r.BuildPackYML != BuildPackYML{}
-	if cmp && len(r.BuildPackYML.RvmVersion) > 0 {
+	if len(r.BuildPackYML.RvmVersion) > 0 {
 		rvmVersion = r.BuildPackYML.RvmVersion
 	}
 	return rvmVersion
 }
 
-func (r Env) installRVM() (packit.BuildResult, error) {
-	rvmLayer, err := r.Context.Layers.Get("rvm")
+// cacheKey returns the properties of the build environment the layer of the
+// installer depends on
+func (r Env) cacheKey(installer RubyInstaller) CacheKey {
+	return CacheKey{
+		Stack:            r.Target.Name(),
+		Arch:             r.Target.Arch,
+		BuildpackVersion: r.Context.BuildpackInfo.Version,
+		URI:              r.Configuration.URI,
+		Installer:        installer.Name(),
+		InstallerVersion: installer.Version(),
+	}
+}
+
+// layerFlags returns whether the RVM layer is needed during build and at
+// launch, any entry of the merged build plan may request either of them
+func (r Env) layerFlags() (bool, bool) {
+	var build, launch bool
+	for _, entry := range r.Context.Plan.Entries {
+		if entry.Name != "rvm" {
+			continue
+		}
+		if value, ok := entry.Metadata["build"].(bool); ok && value {
+			build = true
+		}
+		if value, ok := entry.Metadata["launch"].(bool); ok && value {
+			launch = true
+		}
+	}
+	return build, launch
+}
+
+// rubyVersions returns the primary Ruby version followed by the additional
+// Ruby versions requested in buildpack.yml or BP_RVM_ADDITIONAL_RUBIES
+func (r Env) rubyVersions() []string {
+	return RubyVersions(r.rubyVersion(), r.BuildPackYML)
+}
+
+// rubyInstaller returns the configured Ruby installer backend
+func (r Env) rubyInstaller() (RubyInstaller, error) {
+	name, err := r.Configuration.InstallerName()
+	if err != nil {
+		return nil, err
+	}
+	return NewRubyInstaller(name, r)
+}
+
+func (r Env) installRubies() (packit.BuildResult, error) {
+	// Rubies and gems are installed into a separate layer, so that the
+	// installer, its sources, archives and logs are not exported into the app
+	// image
+	rubyLayer, err := r.Context.Layers.Get(RubyLayerName)
 	if err != nil {
 		return packit.BuildResult{}, err
 	}
+	r.rubyLayerPath = rubyLayer.Path
 
-	var buildMetadata packit.BuildMetadata
-	var launchMetadata packit.LaunchMetadata
+	// downloaded Ruby source archives are kept in a cache-only layer, so
+	// reinstalling a previously seen Ruby version does not download it again
+	archivesLayer, err := r.Context.Layers.Get(ArchivesLayerName)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+	err = os.MkdirAll(archivesLayer.Path, os.ModePerm)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+	archivesLayer.Build, archivesLayer.Cache, archivesLayer.Launch = false, true, false
+	r.archivesLayerPath = archivesLayer.Path
 
-	if rvmLayer.Metadata["rvm_version"] != nil &&
-		rvmLayer.Metadata["rvm_version"].(string) == r.rvmVersion() &&
-		rvmLayer.Metadata["ruby_version"] != nil &&
-		rvmLayer.Metadata["ruby_version"].(string) == r.rubyVersion() {
-		r.Logger.Process("Reusing cached layer %s", rvmLayer.Path)
-		rvmLayer.Build, rvmLayer.Cache, rvmLayer.Launch = true, true, true
-		return packit.BuildResult{
-			Layers: []packit.Layer{rvmLayer},
-			Build:  buildMetadata,
-			Launch: launchMetadata,
-		}, nil
+	archiveCacheSize, err := ArchiveCacheSize()
+	if err != nil {
+		return packit.BuildResult{}, err
 	}
 
-	r.Logger.Process("Installing RVM version '%s' from URI '%s'", r.rvmVersion(), r.Configuration.URI)
+	makeJobs, makeJobsSource, err := MakeJobs(strings.TrimSpace(os.Getenv(MakeJobsEnv)), CgroupPath, runtime.NumCPU())
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
 
-	if rvmLayer, err = rvmLayer.Reset(); err != nil {
-		r.Logger.Process("Resetting RVM layer failed")
+	parallelInstalls, err := ParallelInstalls()
+	if err != nil {
 		return packit.BuildResult{}, err
 	}
+	installerName, err := r.Configuration.InstallerName()
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+	if installerName == RVMInstallerName && parallelInstalls > 1 {
+		r.Logger.Process("RVM compiles one Ruby at a time, ignoring %s=%d", ParallelInstallsEnv, parallelInstalls)
+	}
+	parallelInstalls = LimitParallelInstalls(installerName, parallelInstalls, len(r.rubyVersions()))
 
-	rvmLayer.Metadata = map[string]interface{}{
-		"rvm_version":  r.rvmVersion(),
-		"ruby_version": r.rubyVersion(),
+	// Rubies compiled in parallel share the make jobs
+	r.makeJobs = makeJobs / parallelInstalls
+	if r.makeJobs < 1 {
+		r.makeJobs = 1
+	}
+	if parallelInstalls > 1 {
+		r.Logger.Process("Compiling up to %d Rubies in parallel with %d make jobs each (%s)", parallelInstalls, r.makeJobs, makeJobsSource)
+	} else {
+		r.Logger.Process("Compiling with %d make jobs (%s)", r.makeJobs, makeJobsSource)
 	}
 
-	rvmLayer.Build, rvmLayer.Cache, rvmLayer.Launch = true, true, true
+	installer, err := r.rubyInstaller()
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+	r.Logger.Process("Installing Ruby using %s version %s", installer.Name(), installer.Version())
+	r.Logger.Break()
 
-	err = r.Environment.Configure(rvmLayer.SharedEnv, rvmLayer.Path)
+	installerLayer, err := r.Context.Layers.Get(installer.Name())
 	if err != nil {
 		return packit.BuildResult{}, err
 	}
 
-	// The following commands import GPP keys:
-	// curl -sSL https://rvm.io/mpapis.asc | gpg --import -
-	// curl -sSL https://rvm.io/pkuczynski.asc | gpg --import -
-	// this is necessary because installing RVM on a Ubuntu 16.04 container which
-	// does not have these keys imported yet fails otherwise
-	// see: https://rvm.io/rvm/security
-	// commented for now and kept for future usage
-	gpgBinaryInstalledOutput, _ := exec.Command("which", "gpg").Output()
+	// the source archives of Rubies that are not cached are downloaded while
+	// the installer is set up
+	prefetch := NewTaskGroup(0)
+	for _, rubyVersion := range r.rubyVersions() {
+		archive := installer.SourceArchive(rubyVersion)
+		if archive == "" || contains(metadataStrings(rubyLayer.Metadata["ruby_versions"]), rubyVersion) {
+			continue
+		}
+		prefetch.Go(fmt.Sprintf("prefetching %s", archive), func() error {
+			downloaded, err := PrefetchArchive(RubySourceArchiveURI(archive), filepath.Join(archivesLayer.Path, archive))
+			if downloaded {
+				r.Logger.Process("Prefetched source archive '%s'", archive)
+			}
+			return err
+		})
+	}
+
+	var buildMetadata packit.BuildMetadata
+	var launchMetadata packit.LaunchMetadata
+
+	// every Ruby version is cached independently, so the cached layers are
+	// reused as long as the installer and the build environment did not
+	// change
+	installerCacheKey := r.cacheKey(installer)
+	rubyCacheKey := installerCacheKey
+	rubyCacheKey.InstallOptions = strings.Join(r.Configuration.InstallOptions(), " ")
+
+	// Rubies older than 3.1 do not compile against OpenSSL 3, they are
+	// compiled against a private OpenSSL 1.1 instead
+	var openSSLRubyVersions []string
+	systemOpenSSLVersion := SystemOpenSSLVersion(DefaultIncludeDirs)
+	for _, rubyVersion := range r.rubyVersions() {
+		if !OpenSSLCompatible(rubyVersion, systemOpenSSLVersion) {
+			openSSLRubyVersions = append(openSSLRubyVersions, rubyVersion)
+		}
+	}
+	if len(openSSLRubyVersions) > 0 {
+		rubyCacheKey.OpenSSLVersion = r.Configuration.OpenSSLVersion
+	}
 
-	if len(gpgBinaryInstalledOutput) > 0 {
-		importGPGKey1Cmd := strings.Join([]string{
-			"curl",
-			"-sSL",
-			"https://rvm.io/mpapis.asc",
-			"|",
-			"gpg",
-			"--import",
-			"-",
-		}, " ")
-		err = r.RunBashCmd(importGPGKey1Cmd, &rvmLayer)
+	// patches of the app are applied to the sources of the Ruby version they
+	// are filed under
+	patchesPath := PatchesPath(r.ProjectPath)
+	patches := map[string][]string{}
+	for _, rubyVersion := range r.rubyVersions() {
+		rubyPatches, err := RubyPatches(patchesPath, rubyVersion)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
+		if len(rubyPatches) > 0 {
+			patches[rubyVersion] = rubyPatches
+		}
+	}
+	if len(patches) == 0 && os.Getenv(PatchesPathEnv) != "" {
+		r.Logger.Process("Warning: %s is set but '%s' contains no patches for the requested Ruby versions", PatchesPathEnv, patchesPath)
+	}
+	rubyCacheKey.Patches, err = PatchesChecksum(patches)
+	if err != nil {
+		r.Logger.Detail("Reading the patches in '%s' failed", patchesPath)
+		return packit.BuildResult{}, err
+	}
+
+	var installedRubyVersions []string
+	mismatches := installerCacheKey.Mismatches(installerLayer.Metadata)
+	if installerLayer.Metadata["ruby_layer"] != RubyLayerName {
+		mismatches = append(mismatches, "Rubies are not installed into a separate layer")
+	}
+	if len(mismatches) == 0 {
+		// a build killed while installing leaves a layer behind that still
+		// has the metadata of the previous build
+		if err := LayerManifestFromMetadata(installerLayer.Metadata["manifest"]).Verify(installerLayer.Path); err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("integrity check failed, %s", err))
+		}
+	}
+	if len(mismatches) == 0 {
+		r.Logger.Process("Reusing cached layer %s", installerLayer.Path)
+		if rubyMismatches := rubyCacheKey.Mismatches(rubyLayer.Metadata); len(rubyMismatches) > 0 {
+			if len(rubyLayer.Metadata) > 0 {
+				r.Logger.Process("Not reusing cached layer %s: %s", rubyLayer.Path, strings.Join(rubyMismatches, ", "))
+			}
+		} else if err := LayerManifestFromMetadata(rubyLayer.Metadata["manifest"]).Verify(rubyLayer.Path); err != nil {
+			r.Logger.Process("Not reusing cached layer %s: integrity check failed, %s", rubyLayer.Path, err)
+		} else {
+			installedRubyVersions = metadataStrings(rubyLayer.Metadata["ruby_versions"])
+		}
 
-		importGPGKey2Cmd := strings.Join([]string{
-			"curl",
-			"-sSL",
-			"https://rvm.io/pkuczynski.asc",
-			"|",
-			"gpg",
-			"--import",
-			"-",
-		}, " ")
-		err = r.RunBashCmd(importGPGKey2Cmd, &rvmLayer)
+		for _, rubyVersion := range installedRubyVersions {
+			if !contains(r.rubyVersions(), rubyVersion) {
+				continue
+			}
+			if err := installer.RunRubyCmd(rubyVersion, "ruby -v", &installerLayer); err != nil {
+				r.Logger.Process("Not reusing cached layer %s: Ruby version '%s' does not work", rubyLayer.Path, rubyVersion)
+				installedRubyVersions = nil
+				break
+			}
+		}
+	} else {
+		if len(installerLayer.Metadata) > 0 {
+			r.Logger.Process("Not reusing cached layer %s: %s", installerLayer.Path, strings.Join(mismatches, ", "))
+		}
+
+		// the layers are reset concurrently before setting up the installer,
+		// whose install script may create directories in the Ruby layer
+		resets := NewTaskGroup(0)
+		resets.Go(fmt.Sprintf("resetting the %s layer", installer.Name()), func() error {
+			layer, err := installerLayer.Reset()
+			installerLayer = layer
+			return err
+		})
+		resets.Go("resetting the Ruby layer", func() error {
+			layer, err := rubyLayer.Reset()
+			rubyLayer = layer
+			return err
+		})
+		if err = resets.Wait(); err != nil {
+			r.Logger.Process("Resetting the layers failed")
+			return packit.BuildResult{}, err
+		}
+
+		err = installer.Setup(&installerLayer)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 	}
 
-	shellCmd := strings.Join([]string{
-		"curl",
-		"-vsSL",
-		r.Configuration.URI,
-		"| bash -s -- --version",
-		r.rvmVersion(),
-	}, " ")
-	err = r.RunBashCmd(shellCmd, &rvmLayer)
+	if len(installedRubyVersions) == 0 && len(mismatches) == 0 {
+		if rubyLayer, err = rubyLayer.Reset(); err != nil {
+			r.Logger.Process("Resetting Ruby layer failed")
+			return packit.BuildResult{}, err
+		}
+	}
+
+	build, launch := r.layerFlags()
+	installerLayer.Build, installerLayer.Cache, installerLayer.Launch = build, true, false
+	rubyLayer.Build, rubyLayer.Cache, rubyLayer.Launch = build, true, launch
+
+	for _, variable := range RubyLayerVariables(rubyLayer.Path) {
+		name, value, _ := strings.Cut(variable, "=")
+		rubyLayer.LaunchEnv.Override(name, value)
+	}
+
+	// the exec.d helper sets up the environment of the selected Ruby at launch
+	if launch {
+		rubyLayer.ExecD = []string{filepath.Join(r.Context.CNBPath, "bin", "rvm-env")}
+	}
+
+	rubyVersions := r.rubyVersions()
+	changed := false
+
+	for _, rubyVersion := range RemovedRubyVersions(installedRubyVersions, rubyVersions) {
+		err = installer.RemoveRuby(rubyVersion, &installerLayer)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+	}
+
+	// missing headers would only make the compilation fail after several
+	// minutes, or silently leave out extensions
+	if preflightEnabled() {
+		probe := NewSystemLibraryProbe()
+		for _, rubyVersion := range rubyVersions {
+			if contains(installedRubyVersions, rubyVersion) {
+				continue
+			}
+			if missing := probe.Missing(rubyVersion); len(missing) > 0 {
+				return packit.BuildResult{}, MissingSystemLibrariesError(rubyVersion, r.Target.Name(), missing)
+			}
+		}
+	}
+
+	var openSSLLayer packit.Layer
+	if len(openSSLRubyVersions) > 0 {
+		r.Logger.Process("Ruby version '%s' does not support OpenSSL %s, using OpenSSL %s", strings.Join(openSSLRubyVersions, "', '"), systemOpenSSLVersion, r.Configuration.OpenSSLVersion)
+		openSSLLayer, err = r.installOpenSSL(build, launch)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+	}
+
+	// a failed prefetch is not fatal, the installer downloads the archive
+	// itself
+	if err = prefetch.Wait(); err != nil {
+		r.Logger.Process("Prefetching source archives failed, they are downloaded while installing: %s", err)
+	}
+
+	installs := NewTaskGroup(parallelInstalls)
+	var outputMutex sync.Mutex
+	for _, rubyVersion := range rubyVersions {
+		if contains(installedRubyVersions, rubyVersion) {
+			r.Logger.Process("Reusing cached Ruby version '%s'", rubyVersion)
+			continue
+		}
+		rubyVersion := rubyVersion
+		options := r.Configuration.InstallOptions()
+		if contains(openSSLRubyVersions, rubyVersion) {
+			options = append(options, OpenSSLOptions(openSSLLayer.Path)...)
+		}
+		if len(patches[rubyVersion]) > 0 {
+			var names []string
+			for _, patch := range patches[rubyVersion] {
+				names = append(names, filepath.Base(patch))
+			}
+			r.Logger.Process("Applying patches to Ruby version '%s': %s", rubyVersion, strings.Join(names, ", "))
+		}
+		installs.Go(fmt.Sprintf("installing Ruby version '%s'", rubyVersion), func() error {
+			if parallelInstalls == 1 {
+				return installer.InstallRuby(rubyVersion, options, patches[rubyVersion], &installerLayer)
+			}
+
+			// the output of Rubies compiled in parallel is buffered and
+			// printed as a whole once the compilation finished
+			env, output := r.bufferedEnv()
+			bufferedInstaller, err := NewRubyInstaller(installer.Name(), env)
+			if err != nil {
+				return err
+			}
+			err = bufferedInstaller.InstallRuby(rubyVersion, options, patches[rubyVersion], &installerLayer)
+
+			outputMutex.Lock()
+			defer outputMutex.Unlock()
+			r.Logger.Process("Output of installing Ruby version '%s':", rubyVersion)
+			fmt.Fprint(r.Logger.TitleWriter, output.String())
+			return err
+		})
+		changed = true
+	}
+	if err = installs.Wait(); err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	for _, rubyVersion := range rubyVersions {
+		err = r.verifyRuby(installer, rubyVersion, &installerLayer)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+	}
+
+	err = installer.Finalize(r.rubyVersion(), &installerLayer)
 	if err != nil {
 		return packit.BuildResult{}, err
 	}
 
-	autolibsCmd := strings.Join([]string{
-		filepath.Join(rvmLayer.Path, "bin", "rvm"),
-		"autolibs",
-		"0",
-	}, " ")
-	err = r.RunRvmCmd(autolibsCmd, &rvmLayer)
+	if changed {
+		pruned, err := PruneRubyLayer(rubyLayer.Path, os.Getenv(StripStaticLibsEnv) == "true")
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		r.Logger.Process("Pruned documentation and build-only files from the Ruby layer, saved %s", formatSize(pruned))
+		r.Logger.Break()
+	}
+
+	archives, removedArchives, err := PruneArchiveCache(archivesLayer.Path, metadataStrings(archivesLayer.Metadata["archives"]), rubyVersions, archiveCacheSize)
 	if err != nil {
 		return packit.BuildResult{}, err
 	}
+	for _, archive := range removedArchives {
+		r.Logger.Process("Removed least recently used archive '%s' from the archive cache", archive)
+	}
+	archivesLayer.Metadata = map[string]interface{}{
+		"archives": archives,
+	}
 
-	rubyInstallCmd := strings.Join([]string{
-		filepath.Join(rvmLayer.Path, "bin", "rvm"),
-		"install",
-		r.rubyVersion(),
-	}, " ")
-	err = r.RunRvmCmd(rubyInstallCmd, &rvmLayer)
+	// the installer layer is not part of the launch image, so the default
+	// Ruby is passed on to the exec.d helper
+	defaultRuby, err := findInstalledRuby(filepath.Join(rubyLayer.Path, "rubies"), r.rubyVersion())
 	if err != nil {
 		return packit.BuildResult{}, err
 	}
+	rubyLayer.LaunchEnv.Default(RubySelectionEnv, defaultRuby)
+
+	// subsequent buildpacks use the default Ruby without knowing which
+	// installer was used
+	buildEnvironment, err := LaunchEnvironment(RVMPaths{
+		Rubies: filepath.Join(rubyLayer.Path, "rubies"),
+		Gems:   filepath.Join(rubyLayer.Path, "gems"),
+	}, defaultRuby, "")
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+	for name, value := range buildEnvironment {
+		if name == "PATH" {
+			rubyLayer.BuildEnv.Prepend(name, value, string(os.PathListSeparator))
+		} else {
+			rubyLayer.BuildEnv.Override(name, value)
+		}
+	}
 
-	rvmCleanupCmd := strings.Join([]string{"rvm", "cleanup", "all"}, " ")
-	err = r.RunRvmCmd(rvmCleanupCmd, &rvmLayer)
+	if len(rubyVersions) > 1 {
+		r.Logger.Process("Installed Ruby versions: %s (default: %s, select another one at launch with %s)", strings.Join(rubyVersions, ", "), r.rubyVersion(), RubySelectionEnv)
+		r.Logger.Break()
+	}
+
+	installerManifest, err := NewLayerManifest(installerLayer.Path, installer.ManifestPatterns())
 	if err != nil {
 		return packit.BuildResult{}, err
 	}
 
-	rvmSetDefaultRubyCmd := strings.Join([]string{"rvm", "alias", "create", "default", r.rubyVersion()}, " ")
-	err = r.RunRvmCmd(rvmSetDefaultRubyCmd, &rvmLayer)
+	rubyManifest, err := NewLayerManifest(rubyLayer.Path, RubyLayerManifestPatterns)
 	if err != nil {
 		return packit.BuildResult{}, err
 	}
 
+	installerLayer.Metadata = installerCacheKey.Metadata(map[string]interface{}{
+		"ruby_layer": RubyLayerName,
+		"manifest":   installerManifest,
+	})
+
+	rubyLayer.Metadata = rubyCacheKey.Metadata(map[string]interface{}{
+		"ruby_version":  r.rubyVersion(),
+		"ruby_versions": rubyVersions,
+		"manifest":      rubyManifest,
+	})
+
+	layers := []packit.Layer{installerLayer, rubyLayer, archivesLayer}
+	if len(openSSLRubyVersions) > 0 {
+		layers = append(layers, openSSLLayer)
+	}
+
 	return packit.BuildResult{
-		Layers: []packit.Layer{rvmLayer},
+		Layers: layers,
 		Build:  buildMetadata,
 		Launch: launchMetadata,
 	}, nil
 }
+
+// verifyRuby runs Ruby, requires the configured extensions and runs RubyGems
+// to make sure an installed Ruby is usable
+func (r Env) verifyRuby(installer RubyInstaller, rubyVersion string, layer *packit.Layer) error {
+	r.Logger.Process("Verifying Ruby version '%s'", rubyVersion)
+
+	extensions := r.Configuration.VerifiedExtensions()
+	commands := VerificationCommands(extensions)
+	for i, command := range commands {
+		err := installer.RunRubyCmd(rubyVersion, command, layer)
+		if err == nil {
+			continue
+		}
+
+		switch {
+		case i == 0:
+			return fmt.Errorf("Ruby %s does not run: %w", rubyVersion, err)
+		case i == len(commands)-1:
+			return fmt.Errorf("RubyGems of Ruby %s does not work: %w", rubyVersion, err)
+		default:
+			return MissingExtensionError(rubyVersion, extensions[i-1])
+		}
+	}
+
+	return nil
+}
+
+// metadataStrings converts a list read from layer metadata into a list of
+// strings
+func metadataStrings(value interface{}) []string {
+	var values []string
+	switch list := value.(type) {
+	case []string:
+		values = append(values, list...)
+	case []interface{}:
+		for _, item := range list {
+			values = append(values, fmt.Sprintf("%v", item))
+		}
+	}
+	return values
+}
@@ -0,0 +1,202 @@
+package rvm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// RVMInstaller installs Rubies using RVM
+type RVMInstaller struct {
+	env Env
+}
+
+// NewRVMInstaller returns a new RVMInstaller
+func NewRVMInstaller(env Env) RVMInstaller {
+	return RVMInstaller{
+		env: env,
+	}
+}
+
+// Name returns the name of the RVM backend
+func (i RVMInstaller) Name() string {
+	return RVMInstallerName
+}
+
+// Version returns the version of RVM to install
+func (i RVMInstaller) Version() string {
+	return i.env.rvmVersion()
+}
+
+// ManifestPatterns returns the patterns of the RVM scripts
+func (i RVMInstaller) ManifestPatterns() []string {
+	return RVMLayerManifestPatterns
+}
+
+// Setup installs RVM into the RVM layer and makes subsequent buildpacks use
+// the Rubies in the Ruby layer
+func (i RVMInstaller) Setup(rvmLayer *packit.Layer) error {
+	r := i.env
+
+	err := r.Environment.Configure(rvmLayer.SharedEnv, rvmLayer.Path)
+	if err != nil {
+		return err
+	}
+
+	for _, variable := range RubyLayerVariables(r.rubyLayerPath) {
+		name, value, _ := strings.Cut(variable, "=")
+		rvmLayer.SharedEnv.Override(name, value)
+	}
+
+	r.Logger.Process("Installing RVM version '%s' from URI '%s'", r.rvmVersion(), r.Configuration.RVMReleaseURI(r.rvmVersion()))
+
+	// RVM is installed from its release archive using its own install script
+	// instead of piping the script from get.rvm.io into bash
+	sourcePath, err := ioutil.TempDir("", "rvm-src")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(sourcePath)
+
+	keyring, err := NewKeyring()
+	if err != nil {
+		return err
+	}
+	defer keyring.Close()
+
+	releaseURI := r.Configuration.RVMReleaseURI(r.rvmVersion())
+	archivePath := filepath.Join(sourcePath, "rvm.tar.gz")
+	signaturePath := archivePath + ".asc"
+
+	// gpg cannot import keys into the same keyring concurrently, so the keys
+	// are imported one after another while the release is downloaded
+	tasks := NewTaskGroup(0)
+	tasks.Go("downloading RVM", func() error {
+		return Download(releaseURI, archivePath)
+	})
+	tasks.Go("downloading the signature of RVM", func() error {
+		return Download(releaseURI+".asc", signaturePath)
+	})
+	tasks.Go("importing the keys of the RVM maintainers", func() error {
+		return i.importKeys(keyring)
+	})
+	err = tasks.Wait()
+	if err != nil {
+		r.Logger.Detail("Fetching RVM version '%s' failed", r.rvmVersion())
+		return err
+	}
+
+	err = keyring.Verify(signaturePath, archivePath)
+	if err != nil {
+		r.Logger.Detail("Verifying the signature of RVM version '%s' failed", r.rvmVersion())
+		return err
+	}
+	r.Logger.Process("Verified the signature of RVM version '%s'", r.rvmVersion())
+
+	rvmSourcePath := filepath.Join(sourcePath, "rvm")
+	err = Extract(archivePath, rvmSourcePath, 1)
+	if err != nil {
+		return err
+	}
+
+	installCmd := strings.Join([]string{
+		"cd",
+		rvmSourcePath,
+		"&&",
+		"./install",
+		"--path",
+		rvmLayer.Path,
+		"--ignore-dotfiles",
+	}, " ")
+	err = r.RunBashCmd(installCmd, rvmLayer)
+	if err != nil {
+		return err
+	}
+
+	autolibsCmd := strings.Join([]string{
+		filepath.Join(rvmLayer.Path, "bin", "rvm"),
+		"autolibs",
+		"0",
+	}, " ")
+	return r.RunRvmCmd(autolibsCmd, rvmLayer)
+}
+
+// importKeys imports the keys vendored in this buildpack, which are used to
+// verify the signature of the RVM release archive and have to match the
+// fingerprints pinned in buildpack.toml
+// see: https://rvm.io/rvm/security
+func (i RVMInstaller) importKeys(keyring Keyring) error {
+	keys, err := filepath.Glob(filepath.Join(i.env.Context.CNBPath, KeysDir, "*.asc"))
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys to verify the signature of RVM found in %s", filepath.Join(i.env.Context.CNBPath, KeysDir))
+	}
+
+	for _, key := range keys {
+		err = keyring.Import(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return keyring.VerifyFingerprints(i.env.Configuration.RVMKeyFingerprints)
+}
+
+// InstallRuby installs a Ruby version using "rvm install", which uses a
+// prebuilt binary from the configured server if one is available
+func (i RVMInstaller) InstallRuby(rubyVersion string, options []string, patches []string, rvmLayer *packit.Layer) error {
+	var variables []string
+	if i.env.Configuration.RubyBinaryURI != "" {
+		variables = append(variables, "rvm_remote_server_url="+i.env.Configuration.RubyBinaryURI)
+	}
+
+	rubyInstallCmd := strings.Join(append(append(variables,
+		filepath.Join(rvmLayer.Path, "bin", "rvm"),
+		"install",
+		rubyVersion,
+		"-j",
+		strconv.Itoa(i.env.makeJobs),
+	), options...), " ")
+	if len(patches) > 0 {
+		rubyInstallCmd = strings.Join([]string{rubyInstallCmd, "--patch", strings.Join(patches, ",")}, " ")
+	}
+	return i.env.RunRvmCmd(rubyInstallCmd, rvmLayer)
+}
+
+// SourceArchive returns the name of the source archive RVM downloads
+func (i RVMInstaller) SourceArchive(rubyVersion string) string {
+	return RubySourceArchive(rubyVersion, "tar.bz2")
+}
+
+// RemoveRuby removes a Ruby version using "rvm remove"
+func (i RVMInstaller) RemoveRuby(rubyVersion string, rvmLayer *packit.Layer) error {
+	rvmRemoveCmd := strings.Join([]string{"rvm", "remove", rubyVersion}, " ")
+	return i.env.RunRvmCmd(rvmRemoveCmd, rvmLayer)
+}
+
+// RunRubyCmd runs a command using "rvm do"
+func (i RVMInstaller) RunRubyCmd(rubyVersion, command string, rvmLayer *packit.Layer) error {
+	rvmDoCmd := strings.Join([]string{"rvm", rubyVersion, "do", command}, " ")
+	return i.env.RunRvmCmd(rvmDoCmd, rvmLayer)
+}
+
+// Finalize removes the sources and logs of the installed Rubies and creates
+// the RVM default alias
+func (i RVMInstaller) Finalize(defaultRubyVersion string, rvmLayer *packit.Layer) error {
+	// "rvm cleanup all" would remove the cached archives as well
+	rvmCleanupCmd := strings.Join([]string{"rvm", "cleanup", "sources", "repos", "logs"}, " ")
+	err := i.env.RunRvmCmd(rvmCleanupCmd, rvmLayer)
+	if err != nil {
+		return err
+	}
+
+	rvmSetDefaultRubyCmd := strings.Join([]string{"rvm", "alias", "create", "default", defaultRubyVersion}, " ")
+	return i.env.RunRvmCmd(rvmSetDefaultRubyCmd, rvmLayer)
+}
@@ -0,0 +1,151 @@
+package rvm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// PreflightEnv is the name of the environment variable that disables the
+// check for the system libraries needed to compile Ruby
+const PreflightEnv = "BP_RVM_PREFLIGHT"
+
+// SystemLibrary represents a system library Ruby is compiled against
+type SystemLibrary struct {
+	// Name is the name of the library
+	Name string
+	// Package is the Ubuntu package providing the headers of the library
+	Package string
+	// PkgConfig is the pkg-config module of the library
+	PkgConfig string
+	// Headers are the header files of the library
+	Headers []string
+	// Extension is the Ruby extension built against the library
+	Extension string
+	// MinRubyVersion is the first Ruby version that needs the library
+	MinRubyVersion string
+	// MaxRubyVersion is the first Ruby version that no longer needs the
+	// library
+	MaxRubyVersion string
+	// Optional libraries are not checked before compiling Ruby, because Ruby
+	// can be compiled and works without them
+	Optional bool
+}
+
+// SystemLibraries lists the system libraries Ruby is compiled against
+var SystemLibraries = []SystemLibrary{
+	{Name: "OpenSSL", Package: "libssl-dev", PkgConfig: "openssl", Headers: []string{"openssl/ssl.h"}, Extension: "openssl"},
+	{Name: "zlib", Package: "zlib1g-dev", PkgConfig: "zlib", Headers: []string{"zlib.h"}, Extension: "zlib"},
+	{Name: "libffi", Package: "libffi-dev", PkgConfig: "libffi", Headers: []string{"ffi.h", "ffi/ffi.h"}, Extension: "fiddle"},
+	// psych contains the sources of libyaml up to Ruby 3.1
+	{Name: "libyaml", Package: "libyaml-dev", PkgConfig: "yaml-0.1", Headers: []string{"yaml.h"}, Extension: "psych", MinRubyVersion: "3.2"},
+	{Name: "GNU Readline", Package: "libreadline-dev", PkgConfig: "readline", Headers: []string{"readline/readline.h"}, Extension: "readline", MaxRubyVersion: "3.3", Optional: true},
+	{Name: "GNU dbm", Package: "libgdbm-dev", PkgConfig: "gdbm", Headers: []string{"gdbm.h"}, Extension: "gdbm", MaxRubyVersion: "3.1", Optional: true},
+}
+
+// DefaultIncludeDirs are the directories searched for the headers of system
+// libraries, they may contain glob patterns
+var DefaultIncludeDirs = []string{
+	"/usr/include",
+	"/usr/include/*-linux-gnu",
+	"/usr/local/include",
+}
+
+// RequiredFor returns true if the library is needed to compile the given
+// Ruby version. Libraries are only needed by MRI, a partial version counts
+// as its most recent release.
+func (l SystemLibrary) RequiredFor(rubyVersion string) bool {
+	engine, segments := splitRubyVersion(rubyVersion)
+	if engine != "ruby" {
+		return false
+	}
+	version := strings.Join(segments, ".")
+
+	if l.MinRubyVersion != "" && version != "" &&
+		compareVersionSegments(version, l.MinRubyVersion) < 0 &&
+		!strings.HasPrefix(l.MinRubyVersion, version+".") {
+		return false
+	}
+	if l.MaxRubyVersion != "" && version != "" &&
+		compareVersionSegments(version, l.MaxRubyVersion) >= 0 {
+		return false
+	}
+	return true
+}
+
+// SystemLibraryProbe checks whether the headers of system libraries are
+// installed
+type SystemLibraryProbe struct {
+	// IncludeDirs are the directories searched for header files
+	IncludeDirs []string
+	// PkgConfig is the path of the pkg-config executable, which is asked for
+	// libraries whose headers were not found, or empty to disable it
+	PkgConfig string
+}
+
+// NewSystemLibraryProbe returns a SystemLibraryProbe searching the default
+// include directories and using pkg-config if it is installed
+func NewSystemLibraryProbe() SystemLibraryProbe {
+	pkgConfig, _ := exec.LookPath("pkg-config")
+	return SystemLibraryProbe{
+		IncludeDirs: DefaultIncludeDirs,
+		PkgConfig:   pkgConfig,
+	}
+}
+
+// Available returns true if the headers of the library are installed
+func (p SystemLibraryProbe) Available(library SystemLibrary) bool {
+	for _, includeDir := range p.IncludeDirs {
+		for _, header := range library.Headers {
+			matches, _ := filepath.Glob(filepath.Join(includeDir, header))
+			if len(matches) > 0 {
+				return true
+			}
+		}
+	}
+
+	if p.PkgConfig != "" && library.PkgConfig != "" {
+		return exec.Command(p.PkgConfig, "--exists", library.PkgConfig).Run() == nil
+	}
+
+	return false
+}
+
+// Missing returns the libraries needed to compile the given Ruby version
+// whose headers are not installed
+func (p SystemLibraryProbe) Missing(rubyVersion string) []SystemLibrary {
+	var missing []SystemLibrary
+	for _, library := range SystemLibraries {
+		if library.Optional || !library.RequiredFor(rubyVersion) {
+			continue
+		}
+		if !p.Available(library) {
+			missing = append(missing, library)
+		}
+	}
+	return missing
+}
+
+// MissingSystemLibrariesError returns an error listing the packages to add
+// to the build image of the given stack
+func MissingSystemLibrariesError(rubyVersion, stack string, missing []SystemLibrary) error {
+	var libraries, packages []string
+	for _, library := range missing {
+		libraries = append(libraries, library.Name)
+		packages = append(packages, library.Package)
+	}
+
+	if stack == "" {
+		stack = "the stack"
+	}
+
+	return fmt.Errorf("compiling Ruby %s requires %s, add the packages %s to the build image of %s or set %s=false to skip this check", rubyVersion, strings.Join(libraries, ", "), strings.Join(packages, " "), stack, PreflightEnv)
+}
+
+// preflightEnabled returns false if the check for system libraries has been
+// disabled
+func preflightEnabled() bool {
+	return os.Getenv(PreflightEnv) != "false"
+}
@@ -0,0 +1,102 @@
+package rvm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testSystemLibraries(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		includeDir string
+		probe      rvm.SystemLibraryProbe
+	)
+
+	writeHeader := func(path string) {
+		path = filepath.Join(includeDir, path)
+		Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte{}, 0644)).To(Succeed())
+	}
+
+	library := func(name string) rvm.SystemLibrary {
+		for _, library := range rvm.SystemLibraries {
+			if library.Name == name {
+				return library
+			}
+		}
+		t.Fatalf("unknown library %s", name)
+		return rvm.SystemLibrary{}
+	}
+
+	it.Before(func() {
+		var err error
+		includeDir, err = ioutil.TempDir("", "include")
+		Expect(err).NotTo(HaveOccurred())
+
+		probe = rvm.SystemLibraryProbe{
+			IncludeDirs: []string{filepath.Join(includeDir, "usr", "include"), filepath.Join(includeDir, "usr", "include", "*-linux-gnu")},
+		}
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(includeDir)).To(Succeed())
+	})
+
+	context("RequiredFor", func() {
+		it("requires libyaml starting with Ruby 3.2", func() {
+			Expect(library("libyaml").RequiredFor("3.1.4")).To(BeFalse())
+			Expect(library("libyaml").RequiredFor("3.2.2")).To(BeTrue())
+			Expect(library("libyaml").RequiredFor("ruby-3.3.0")).To(BeTrue())
+		})
+
+		it("treats partial versions as their most recent release", func() {
+			Expect(library("libyaml").RequiredFor("3")).To(BeTrue())
+			Expect(library("libyaml").RequiredFor("3.1")).To(BeFalse())
+		})
+
+		it("does not require libraries that are no longer needed", func() {
+			Expect(library("GNU Readline").RequiredFor("3.2.2")).To(BeTrue())
+			Expect(library("GNU Readline").RequiredFor("3.3.0")).To(BeFalse())
+		})
+
+		it("does not require libraries for other engines", func() {
+			Expect(library("OpenSSL").RequiredFor("jruby-9.3.4.0")).To(BeFalse())
+		})
+	})
+
+	context("Missing", func() {
+		it("returns the required libraries whose headers are missing", func() {
+			writeHeader("usr/include/zlib.h")
+			writeHeader("usr/include/x86_64-linux-gnu/ffi.h")
+
+			var names []string
+			for _, library := range probe.Missing("3.2.2") {
+				names = append(names, library.Name)
+			}
+			Expect(names).To(Equal([]string{"OpenSSL", "libyaml"}))
+		})
+
+		it("returns nothing if all headers are installed", func() {
+			writeHeader("usr/include/openssl/ssl.h")
+			writeHeader("usr/include/zlib.h")
+			writeHeader("usr/include/ffi/ffi.h")
+
+			Expect(probe.Missing("2.7.6")).To(BeEmpty())
+		})
+	})
+
+	context("MissingSystemLibrariesError", func() {
+		it("lists the packages to add to the stack", func() {
+			err := rvm.MissingSystemLibrariesError("3.2.2", "io.buildpacks.stacks.bionic", []rvm.SystemLibrary{library("OpenSSL"), library("libyaml")})
+			Expect(err).To(MatchError("compiling Ruby 3.2.2 requires OpenSSL, libyaml, add the packages libssl-dev libyaml-dev to the build image of io.buildpacks.stacks.bionic or set BP_RVM_PREFLIGHT=false to skip this check"))
+		})
+	})
+}
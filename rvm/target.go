@@ -0,0 +1,125 @@
+package rvm
+
+import (
+	"os"
+	"runtime"
+)
+
+// Names of the environment variables describing the target of the build
+// image, set by platforms implementing Platform API 0.12 or later
+const (
+	TargetOSEnv            = "CNB_TARGET_OS"
+	TargetArchEnv          = "CNB_TARGET_ARCH"
+	TargetDistroNameEnv    = "CNB_TARGET_DISTRO_NAME"
+	TargetDistroVersionEnv = "CNB_TARGET_DISTRO_VERSION"
+)
+
+// StackDistros maps the IDs of the supported stacks to their distribution and
+// version, so platforms only setting CNB_STACK_ID select the same defaults
+var StackDistros = map[string][2]string{
+	"io.buildpacks.stacks.bionic":        {"ubuntu", "18.04"},
+	"io.buildpacks.stacks.jammy":         {"ubuntu", "22.04"},
+	"org.cloudfoundry.stacks.cflinuxfs3": {"ubuntu", "18.04"},
+	"org.cloudfoundry.stacks.cflinuxfs4": {"ubuntu", "22.04"},
+}
+
+// Target represents the operating system, architecture and distribution of
+// the build image
+type Target struct {
+	Stack         string
+	OS            string
+	Arch          string
+	Distro        string
+	DistroVersion string
+}
+
+// TargetFromEnv returns the target read from the CNB_TARGET_* environment
+// variables. The distribution is derived from the given stack ID if the
+// platform does not set it, the architecture defaults to the one this
+// buildpack runs on.
+func TargetFromEnv(stack string) Target {
+	target := Target{
+		Stack:         stack,
+		OS:            os.Getenv(TargetOSEnv),
+		Arch:          os.Getenv(TargetArchEnv),
+		Distro:        os.Getenv(TargetDistroNameEnv),
+		DistroVersion: os.Getenv(TargetDistroVersionEnv),
+	}
+
+	if target.OS == "" {
+		target.OS = "linux"
+	}
+	if target.Arch == "" {
+		target.Arch = runtime.GOARCH
+	}
+	target.Arch = NormalizeArch(target.Arch)
+	if distro, ok := StackDistros[stack]; ok && target.Distro == "" {
+		target.Distro, target.DistroVersion = distro[0], distro[1]
+	}
+
+	return target
+}
+
+// NormalizeArch returns the Go name of an architecture, which is used by the
+// CNB specification as well, e.g. "arm64" for "aarch64"
+func NormalizeArch(arch string) string {
+	switch arch {
+	case "x86_64", "x86-64":
+		return "amd64"
+	case "aarch64", "arm64/v8":
+		return "arm64"
+	}
+	return arch
+}
+
+// String returns the operating system and architecture of the target, e.g.
+// "linux/arm64", followed by its name
+func (t Target) String() string {
+	if name := t.Name(); name != "" {
+		return t.OS + "/" + t.Arch + " (" + name + ")"
+	}
+	return t.OS + "/" + t.Arch
+}
+
+// Name returns the stack ID or, on platforms without stacks, the
+// distribution and its version, e.g. "ubuntu-22.04"
+func (t Target) Name() string {
+	if t.Stack != "" {
+		return t.Stack
+	}
+	if t.Distro != "" {
+		return t.Distro + "-" + t.DistroVersion
+	}
+	return ""
+}
+
+// TargetConfiguration represents stack-specific settings in buildpack.toml,
+// which override the general configuration for matching targets
+type TargetConfiguration struct {
+	Stacks             []string `toml:"stacks"`
+	Arch               string   `toml:"arch"`
+	Distro             string   `toml:"distro"`
+	DistroVersion      string   `toml:"distro_version"`
+	DefaultRubyVersion string   `toml:"default_ruby_version"`
+	InstallOptions     []string `toml:"install_options"`
+	RubyBinaryURI      string   `toml:"ruby_binary_uri"`
+}
+
+// Matches returns true if the settings apply to the given target, either by
+// its stack ID or by its distribution and version, settings without either
+// apply to every target. Settings with an
+// architecture, e.g. the URI of prebuilt Rubies, only apply to targets of
+// that architecture.
+func (c TargetConfiguration) Matches(target Target) bool {
+	if c.Arch != "" && NormalizeArch(c.Arch) != target.Arch {
+		return false
+	}
+	if len(c.Stacks) == 0 && c.Distro == "" {
+		return true
+	}
+	if target.Stack != "" && contains(c.Stacks, target.Stack) {
+		return true
+	}
+	return c.Distro != "" && c.Distro == target.Distro &&
+		(c.DistroVersion == "" || c.DistroVersion == target.DistroVersion)
+}
@@ -0,0 +1,148 @@
+package rvm_test
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testTarget(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	it.After(func() {
+		for _, name := range []string{"CNB_TARGET_OS", "CNB_TARGET_ARCH", "CNB_TARGET_DISTRO_NAME", "CNB_TARGET_DISTRO_VERSION"} {
+			Expect(os.Unsetenv(name)).To(Succeed())
+		}
+	})
+
+	context("TargetFromEnv", func() {
+		it("reads the target from the environment", func() {
+			Expect(os.Setenv("CNB_TARGET_OS", "linux")).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_ARCH", "arm64")).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_DISTRO_NAME", "ubuntu")).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_DISTRO_VERSION", "24.04")).To(Succeed())
+
+			Expect(rvm.TargetFromEnv("")).To(Equal(rvm.Target{
+				OS:            "linux",
+				Arch:          "arm64",
+				Distro:        "ubuntu",
+				DistroVersion: "24.04",
+			}))
+		})
+
+		it("derives the distribution from the stack ID", func() {
+			Expect(rvm.TargetFromEnv("io.buildpacks.stacks.jammy")).To(Equal(rvm.Target{
+				Stack:         "io.buildpacks.stacks.jammy",
+				OS:            "linux",
+				Arch:          runtime.GOARCH,
+				Distro:        "ubuntu",
+				DistroVersion: "22.04",
+			}))
+		})
+
+		it("prefers the distribution set by the platform", func() {
+			Expect(os.Setenv("CNB_TARGET_DISTRO_NAME", "ubuntu")).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_DISTRO_VERSION", "24.04")).To(Succeed())
+
+			Expect(rvm.TargetFromEnv("io.buildpacks.stacks.jammy").DistroVersion).To(Equal("24.04"))
+		})
+	})
+
+	context("NormalizeArch", func() {
+		it("returns the Go name of the architecture", func() {
+			Expect(rvm.NormalizeArch("aarch64")).To(Equal("arm64"))
+			Expect(rvm.NormalizeArch("x86_64")).To(Equal("amd64"))
+			Expect(rvm.NormalizeArch("arm64")).To(Equal("arm64"))
+		})
+
+		it("normalizes the architecture set by the platform", func() {
+			Expect(os.Setenv("CNB_TARGET_ARCH", "aarch64")).To(Succeed())
+
+			Expect(rvm.TargetFromEnv("").Arch).To(Equal("arm64"))
+		})
+	})
+
+	context("String", func() {
+		it("describes the target", func() {
+			Expect(rvm.Target{OS: "linux", Arch: "arm64", Distro: "ubuntu", DistroVersion: "22.04"}.String()).To(Equal("linux/arm64 (ubuntu-22.04)"))
+			Expect(rvm.Target{OS: "linux", Arch: "amd64"}.String()).To(Equal("linux/amd64"))
+		})
+	})
+
+	context("TargetConfiguration", func() {
+		it("only applies settings of an architecture to targets of that architecture", func() {
+			configuration := rvm.TargetConfiguration{Arch: "arm64", Distro: "ubuntu"}
+
+			Expect(configuration.Matches(rvm.Target{Arch: "arm64", Distro: "ubuntu", DistroVersion: "22.04"})).To(BeTrue())
+			Expect(configuration.Matches(rvm.Target{Arch: "amd64", Distro: "ubuntu", DistroVersion: "22.04"})).To(BeFalse())
+		})
+
+		it("applies settings without stacks and distribution to every target of the architecture", func() {
+			configuration := rvm.TargetConfiguration{Arch: "aarch64"}
+
+			Expect(configuration.Matches(rvm.Target{Stack: "io.buildpacks.stacks.jammy", Arch: "arm64"})).To(BeTrue())
+			Expect(configuration.Matches(rvm.Target{Stack: "io.buildpacks.stacks.jammy", Arch: "amd64"})).To(BeFalse())
+		})
+	})
+
+	context("Name", func() {
+		it("returns the stack ID or the distribution", func() {
+			Expect(rvm.Target{Stack: "io.buildpacks.stacks.jammy", Distro: "ubuntu", DistroVersion: "22.04"}.Name()).To(Equal("io.buildpacks.stacks.jammy"))
+			Expect(rvm.Target{Distro: "ubuntu", DistroVersion: "22.04"}.Name()).To(Equal("ubuntu-22.04"))
+			Expect(rvm.Target{}.Name()).To(BeEmpty())
+		})
+	})
+
+	context("buildpack.toml", func() {
+		it("lists the distribution of every stack as a target of every architecture", func() {
+			var buildpackTOML struct {
+				API    string `toml:"api"`
+				Stacks []struct {
+					ID string `toml:"id"`
+				} `toml:"stacks"`
+				Targets []struct {
+					OS      string `toml:"os"`
+					Arch    string `toml:"arch"`
+					Distros []struct {
+						Name    string `toml:"name"`
+						Version string `toml:"version"`
+					} `toml:"distros"`
+				} `toml:"targets"`
+			}
+			_, err := toml.DecodeFile("../buildpack.toml", &buildpackTOML)
+			Expect(err).NotTo(HaveOccurred())
+
+			// targets are only understood since Buildpack API 0.10
+			Expect(buildpackTOML.API).To(Equal("0.10"))
+
+			stackDistros := map[string]bool{}
+			for _, stack := range buildpackTOML.Stacks {
+				distro, ok := rvm.StackDistros[stack.ID]
+				Expect(ok).To(BeTrue(), "unknown stack %s", stack.ID)
+				stackDistros[distro[0]+"-"+distro[1]] = true
+			}
+
+			var distros []string
+			for distro := range stackDistros {
+				distros = append(distros, distro)
+			}
+
+			Expect(buildpackTOML.Targets).NotTo(BeEmpty())
+			for _, target := range buildpackTOML.Targets {
+				var targetDistros []string
+				for _, distro := range target.Distros {
+					targetDistros = append(targetDistros, distro.Name+"-"+distro.Version)
+				}
+				Expect(targetDistros).To(ConsistOf(distros), "distributions of the target %s/%s", target.OS, target.Arch)
+			}
+		})
+	})
+}
@@ -0,0 +1,41 @@
+package rvm
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// ToolVersionsParser represents a parser for the .tool-versions file used by
+// asdf, see: https://asdf-vm.com/manage/configuration.html#tool-versions
+type ToolVersionsParser struct{}
+
+// NewToolVersionsParser creates a new .tool-versions parser
+func NewToolVersionsParser() ToolVersionsParser {
+	return ToolVersionsParser{}
+}
+
+// ParseVersion looks for a .tool-versions file in a given path and, if it
+// exists, returns the first version listed for the tool "ruby"
+func (r ToolVersionsParser) ParseVersion(path string) (string, error) {
+	toolVersions, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer toolVersions.Close()
+
+	scanner := bufio.NewScanner(toolVersions)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) > 1 && fields[0] == "ruby" {
+			return fields[1], nil
+		}
+	}
+
+	return "", scanner.Err()
+}
@@ -0,0 +1,62 @@
+package rvm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testToolVersionsParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workDir            string
+		toolVersionsParser rvm.ToolVersionsParser
+	)
+
+	it.Before(func() {
+		var err error
+
+		workDir, err = ioutil.TempDir("", "workDir")
+		Expect(err).NotTo(HaveOccurred())
+
+		toolVersionsParser = rvm.NewToolVersionsParser()
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workDir)).To(Succeed())
+	})
+
+	context("when a .tool-versions file is present", func() {
+		it("returns the first ruby version after parsing .tool-versions", func() {
+			err := ioutil.WriteFile(filepath.Join(workDir, ".tool-versions"), []byte("# tools\nnodejs 18.12.1\nruby 3.1.2 system # fallback\n"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			rubyVersion, err := toolVersionsParser.ParseVersion(filepath.Join(workDir, ".tool-versions"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rubyVersion).To(Equal("3.1.2"))
+		})
+
+		it("returns an empty version if ruby is not listed", func() {
+			err := ioutil.WriteFile(filepath.Join(workDir, ".tool-versions"), []byte("nodejs 18.12.1\n"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			rubyVersion, err := toolVersionsParser.ParseVersion(filepath.Join(workDir, ".tool-versions"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rubyVersion).To(BeEmpty())
+		})
+	})
+
+	context("when no .tool-versions file is present", func() {
+		it("returns an error", func() {
+			_, err := toolVersionsParser.ParseVersion(filepath.Join(workDir, ".tool-versions"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+}
@@ -0,0 +1,58 @@
+package rvm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// VerifiedExtensionsEnv is the name of the environment variable that lists
+// the Ruby extensions every installed Ruby has to provide
+const VerifiedExtensionsEnv = "BP_RVM_VERIFIED_EXTENSIONS"
+
+// DefaultVerifiedExtensions lists the extensions every installed Ruby has to
+// provide unless configured otherwise. They are only built if the
+// corresponding system libraries are available when compiling Ruby.
+var DefaultVerifiedExtensions = []string{"openssl", "psych", "zlib"}
+
+// VerifiedExtensions returns the Ruby extensions every installed Ruby has to
+// provide. The list can be set using the environment variable
+// BP_RVM_VERIFIED_EXTENSIONS or "verified_extensions" in buildpack.toml,
+// "none" disables the verification of extensions.
+func (c Configuration) VerifiedExtensions() []string {
+	extensions := DefaultVerifiedExtensions
+	if c.VerifiedExtensionList != nil {
+		extensions = c.VerifiedExtensionList
+	}
+	if value, ok := os.LookupEnv(VerifiedExtensionsEnv); ok && value != "" {
+		extensions = nil
+		for _, extension := range strings.Split(value, ",") {
+			if extension = strings.TrimSpace(extension); extension != "" && extension != "none" {
+				extensions = append(extensions, extension)
+			}
+		}
+	}
+	return extensions
+}
+
+// VerificationCommands returns the commands that are run in the environment
+// of an installed Ruby to verify it, the first one checks Ruby itself, the
+// last one RubyGems and the others require one extension each
+func VerificationCommands(extensions []string) []string {
+	commands := []string{"ruby -v"}
+	for _, extension := range extensions {
+		commands = append(commands, fmt.Sprintf(`ruby -e 'require "%s"'`, extension))
+	}
+	return append(commands, "gem env")
+}
+
+// MissingExtensionError returns an error explaining which system library is
+// needed to build a missing Ruby extension
+func MissingExtensionError(rubyVersion, extension string) error {
+	for _, library := range SystemLibraries {
+		if library.Extension == extension {
+			return fmt.Errorf("Ruby %s was installed without the extension '%s' because %s was not found, add the package %s to the build image", rubyVersion, extension, library.Name, library.Package)
+		}
+	}
+	return fmt.Errorf("Ruby %s was installed without the extension '%s', make sure the system libraries it requires are available in the build image", rubyVersion, extension)
+}
@@ -0,0 +1,63 @@
+package rvm_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testVerification(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("VerifiedExtensions", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("BP_RVM_VERIFIED_EXTENSIONS")).To(Succeed())
+		})
+
+		it("returns the default extensions", func() {
+			Expect(rvm.Configuration{}.VerifiedExtensions()).To(Equal([]string{"openssl", "psych", "zlib"}))
+		})
+
+		it("returns the extensions from buildpack.toml", func() {
+			configuration := rvm.Configuration{VerifiedExtensionList: []string{"openssl"}}
+			Expect(configuration.VerifiedExtensions()).To(Equal([]string{"openssl"}))
+		})
+
+		it("prefers the environment variable", func() {
+			Expect(os.Setenv("BP_RVM_VERIFIED_EXTENSIONS", "zlib, readline")).To(Succeed())
+
+			configuration := rvm.Configuration{VerifiedExtensionList: []string{"openssl"}}
+			Expect(configuration.VerifiedExtensions()).To(Equal([]string{"zlib", "readline"}))
+		})
+
+		it("disables the verification of extensions", func() {
+			Expect(os.Setenv("BP_RVM_VERIFIED_EXTENSIONS", "none")).To(Succeed())
+			Expect(rvm.Configuration{}.VerifiedExtensions()).To(BeEmpty())
+		})
+	})
+
+	context("VerificationCommands", func() {
+		it("runs Ruby, requires the extensions and runs RubyGems", func() {
+			Expect(rvm.VerificationCommands([]string{"openssl", "psych"})).To(Equal([]string{
+				"ruby -v",
+				`ruby -e 'require "openssl"'`,
+				`ruby -e 'require "psych"'`,
+				"gem env",
+			}))
+		})
+	})
+
+	context("MissingExtensionError", func() {
+		it("names the package providing the system library", func() {
+			Expect(rvm.MissingExtensionError("2.7.6", "psych")).To(MatchError("Ruby 2.7.6 was installed without the extension 'psych' because libyaml was not found, add the package libyaml-dev to the build image"))
+		})
+
+		it("explains unknown extensions", func() {
+			Expect(rvm.MissingExtensionError("2.7.6", "nokogiri")).To(MatchError("Ruby 2.7.6 was installed without the extension 'nokogiri', make sure the system libraries it requires are available in the build image"))
+		})
+	})
+}
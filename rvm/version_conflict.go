@@ -0,0 +1,215 @@
+package rvm
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VersionConflictEnv is the name of the environment variable that selects how
+// conflicting Ruby versions from different version sources are handled
+const VersionConflictEnv = "BP_RVM_VERSION_CONFLICT"
+
+// Possible values of the environment variable BP_RVM_VERSION_CONFLICT
+const (
+	VersionConflictFail   = "fail"
+	VersionConflictWarn   = "warn"
+	VersionConflictIgnore = "ignore"
+)
+
+// patchLevelRegEx matches the patch level suffix of a Ruby version as written
+// by Bundler ("2.6.5p114") or RVM ("2.6.5-p114")
+var patchLevelRegEx = regexp.MustCompile(`-?p[0-9]+$`)
+
+// VersionCandidate represents the Ruby version found in a single version
+// source. Sources like the Gemfile may request a range of versions, in which
+// case the requirement is kept next to the version derived from it.
+type VersionCandidate struct {
+	Source      string
+	Version     string
+	Requirement string
+}
+
+// Requested returns the requirement of the candidate or, if there is none,
+// its version
+func (c VersionCandidate) Requested() string {
+	if c.Requirement != "" {
+		return c.Requirement
+	}
+	return c.Version
+}
+
+// VersionConflict represents two version sources that request Ruby versions
+// which cannot both be satisfied
+type VersionConflict struct {
+	First  VersionCandidate
+	Second VersionCandidate
+}
+
+func (c VersionConflict) String() string {
+	return fmt.Sprintf("%s requests Ruby %s but %s requests Ruby %s", c.First.Source, c.First.Requested(), c.Second.Source, c.Second.Requested())
+}
+
+// VersionConflictMode returns the configured way of handling conflicting
+// Ruby versions, which defaults to "fail"
+func VersionConflictMode() (string, error) {
+	mode, ok := os.LookupEnv(VersionConflictEnv)
+	if !ok || mode == "" {
+		return VersionConflictFail, nil
+	}
+
+	switch mode {
+	case VersionConflictFail, VersionConflictWarn, VersionConflictIgnore:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("invalid value '%s' for %s, expected one of: %s, %s, %s", mode, VersionConflictEnv, VersionConflictFail, VersionConflictWarn, VersionConflictIgnore)
+}
+
+// FindVersionConflicts compares the versions of all candidates with each other
+// and returns every pair that cannot be satisfied at the same time
+func FindVersionConflicts(candidates []VersionCandidate) []VersionConflict {
+	var conflicts []VersionConflict
+	for i := range candidates {
+		for j := i + 1; j < len(candidates); j++ {
+			if !compatibleCandidates(candidates[i], candidates[j]) {
+				conflicts = append(conflicts, VersionConflict{
+					First:  candidates[i],
+					Second: candidates[j],
+				})
+			}
+		}
+	}
+	return conflicts
+}
+
+// compatibleCandidates returns true if the same Ruby installation satisfies
+// both candidates, comparing versions with requirements where there are any
+func compatibleCandidates(a, b VersionCandidate) bool {
+	switch {
+	case a.Requirement != "" && b.Requirement != "":
+		return SatisfiesRequirement(a.Version, b.Requirement) || SatisfiesRequirement(b.Version, a.Requirement)
+	case a.Requirement != "":
+		return SatisfiesRequirement(b.Version, a.Requirement)
+	case b.Requirement != "":
+		return SatisfiesRequirement(a.Version, b.Requirement)
+	}
+	return CompatibleRubyVersions(a.Version, b.Version)
+}
+
+// SatisfiesRequirement returns true if the given MRI version satisfies every
+// part of a RubyGems style requirement like ">= 2.7, < 3.2". A partial
+// version like "3.1" satisfies it if one of its releases may do so.
+func SatisfiesRequirement(version, requirement string) bool {
+	engine, segments := splitRubyVersion(version)
+	if engine != "ruby" {
+		return false
+	}
+
+	for _, part := range strings.Split(requirement, ",") {
+		operator, bound := splitRequirement(part)
+		if bound == "" {
+			continue
+		}
+		_, boundSegments := splitRubyVersion(bound)
+		if !satisfiesConstraint(segments, operator, boundSegments) {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfiesConstraint compares version segments with a single constraint
+// like ">= 2.7"
+func satisfiesConstraint(segments []string, operator string, bound []string) bool {
+	// a partial version may match any release starting with it
+	if len(segments) < len(bound) && compareRubySegments(segments, bound[:len(segments)]) == 0 {
+		return true
+	}
+
+	comparison := compareRubySegments(segments, bound)
+	switch operator {
+	case "!=":
+		return comparison != 0
+	case ">":
+		return comparison > 0
+	case ">=":
+		return comparison >= 0
+	case "<":
+		return comparison < 0
+	case "<=":
+		return comparison <= 0
+	case "~>":
+		// "~> 3.1" allows anything below 4, "~> 3.1.2" anything below 3.2
+		upper := append([]string{}, bound...)
+		if len(upper) > 1 {
+			upper = upper[:len(upper)-1]
+		}
+		last, err := strconv.Atoi(upper[len(upper)-1])
+		if err != nil {
+			return comparison >= 0
+		}
+		upper[len(upper)-1] = strconv.Itoa(last + 1)
+		return comparison >= 0 && compareRubySegments(segments, upper) < 0
+	}
+	return comparison == 0
+}
+
+// compareRubySegments compares two versions split into segments, missing
+// segments count as zero so that "3.2" equals "3.2.0"
+func compareRubySegments(a, b []string) int {
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	for len(a) < len(b) {
+		a = append(a, "0")
+	}
+	for len(b) < len(a) {
+		b = append(b, "0")
+	}
+	return compareVersionSegments(strings.Join(a, "."), strings.Join(b, "."))
+}
+
+// CompatibleRubyVersions returns true if both Ruby versions can be satisfied
+// by the same Ruby installation. Partial versions like "3.1" are compatible
+// with every version they are a prefix of, e.g. "3.1.2", and patch levels
+// are ignored.
+func CompatibleRubyVersions(a, b string) bool {
+	engineA, segmentsA := splitRubyVersion(a)
+	engineB, segmentsB := splitRubyVersion(b)
+	if engineA != engineB {
+		return false
+	}
+
+	if len(segmentsA) > len(segmentsB) {
+		segmentsA, segmentsB = segmentsB, segmentsA
+	}
+	for i := range segmentsA {
+		if segmentsA[i] != segmentsB[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitRubyVersion splits an RVM Ruby string like "ruby-2.7.1-p83" or
+// "jruby-9.3.4.0" into the engine and the version segments
+func splitRubyVersion(version string) (string, []string) {
+	version = strings.TrimSpace(version)
+
+	engine := "ruby"
+	if version != "" && !strings.ContainsAny(version[:1], "0123456789") {
+		engine, version = version, ""
+		if index := strings.Index(engine, "-"); index > 0 {
+			engine, version = engine[:index], engine[index+1:]
+		}
+	}
+	version = patchLevelRegEx.ReplaceAllString(version, "")
+
+	if version == "" {
+		return engine, nil
+	}
+
+	return engine, strings.Split(version, ".")
+}
@@ -0,0 +1,109 @@
+package rvm_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/avarteqgmbh/rvm-cnb/rvm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testVersionConflict(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("CompatibleRubyVersions", func() {
+		it("treats partial versions and patch levels as compatible", func() {
+			Expect(rvm.CompatibleRubyVersions("2.7.0p0", "2.7.0")).To(BeTrue())
+			Expect(rvm.CompatibleRubyVersions("2.6.5-p114", "2.6.5p114")).To(BeTrue())
+			Expect(rvm.CompatibleRubyVersions("3", "3.1.2")).To(BeTrue())
+			Expect(rvm.CompatibleRubyVersions("ruby-3.1.2", "3.1")).To(BeTrue())
+			Expect(rvm.CompatibleRubyVersions("jruby", "jruby-9.3.4.0")).To(BeTrue())
+		})
+
+		it("detects versions that cannot both be satisfied", func() {
+			Expect(rvm.CompatibleRubyVersions("2.7.0", "3.1.2")).To(BeFalse())
+			Expect(rvm.CompatibleRubyVersions("3.1.2", "3.1.3")).To(BeFalse())
+			Expect(rvm.CompatibleRubyVersions("jruby-9.3.4.0", "2.6.8")).To(BeFalse())
+		})
+	})
+
+	context("SatisfiesRequirement", func() {
+		it("checks versions against all parts of the requirement", func() {
+			Expect(rvm.SatisfiesRequirement("3.1.2", ">= 2.7")).To(BeTrue())
+			Expect(rvm.SatisfiesRequirement("3.1.2p20", "~> 3.1")).To(BeTrue())
+			Expect(rvm.SatisfiesRequirement("3.1.4", "~> 3.1.2")).To(BeTrue())
+			Expect(rvm.SatisfiesRequirement("2.7.6", ">= 2.7.0, < 3.2")).To(BeTrue())
+			Expect(rvm.SatisfiesRequirement("3.2.0", "~> 3.1.2")).To(BeFalse())
+			Expect(rvm.SatisfiesRequirement("4.0.0", "~> 3.1")).To(BeFalse())
+			Expect(rvm.SatisfiesRequirement("3.2.1", ">= 2.7.0, < 3.2")).To(BeFalse())
+			Expect(rvm.SatisfiesRequirement("jruby-9.3.4.0", ">= 2.7")).To(BeFalse())
+		})
+
+		it("accepts partial versions if one of their releases may satisfy the requirement", func() {
+			Expect(rvm.SatisfiesRequirement("3.1", ">= 3.1.2")).To(BeTrue())
+			Expect(rvm.SatisfiesRequirement("3", "~> 3.1")).To(BeTrue())
+			Expect(rvm.SatisfiesRequirement("3.0", ">= 3.1.2")).To(BeFalse())
+		})
+	})
+
+	context("FindVersionConflicts", func() {
+		it("compares versions with the requirements of other candidates", func() {
+			Expect(rvm.FindVersionConflicts([]rvm.VersionCandidate{
+				{Source: ".ruby-version", Version: "3.1.2"},
+				{Source: "Gemfile", Version: "2.7", Requirement: ">= 2.7"},
+			})).To(BeEmpty())
+
+			Expect(rvm.FindVersionConflicts([]rvm.VersionCandidate{
+				{Source: ".ruby-version", Version: "2.7.6"},
+				{Source: "Gemfile", Version: "3.1", Requirement: "~> 3.1"},
+			})).To(HaveLen(1))
+		})
+
+		it("returns every conflicting pair", func() {
+			conflicts := rvm.FindVersionConflicts([]rvm.VersionCandidate{
+				{Source: "Gemfile.lock", Version: "2.7.0p0"},
+				{Source: "Gemfile", Version: "2.7"},
+				{Source: ".ruby-version", Version: "3.1.2"},
+			})
+			Expect(conflicts).To(Equal([]rvm.VersionConflict{
+				{
+					First:  rvm.VersionCandidate{Source: "Gemfile.lock", Version: "2.7.0p0"},
+					Second: rvm.VersionCandidate{Source: ".ruby-version", Version: "3.1.2"},
+				},
+				{
+					First:  rvm.VersionCandidate{Source: "Gemfile", Version: "2.7"},
+					Second: rvm.VersionCandidate{Source: ".ruby-version", Version: "3.1.2"},
+				},
+			}))
+		})
+	})
+
+	context("VersionConflictMode", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("BP_RVM_VERSION_CONFLICT")).To(Succeed())
+		})
+
+		it("defaults to fail", func() {
+			mode, err := rvm.VersionConflictMode()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mode).To(Equal("fail"))
+		})
+
+		it("returns the configured mode", func() {
+			Expect(os.Setenv("BP_RVM_VERSION_CONFLICT", "ignore")).To(Succeed())
+
+			mode, err := rvm.VersionConflictMode()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mode).To(Equal("ignore"))
+		})
+
+		it("returns an error for an unknown mode", func() {
+			Expect(os.Setenv("BP_RVM_VERSION_CONFLICT", "panic")).To(Succeed())
+
+			_, err := rvm.VersionConflictMode()
+			Expect(err).To(MatchError("invalid value 'panic' for BP_RVM_VERSION_CONFLICT, expected one of: fail, warn, ignore"))
+		})
+	})
+}
@@ -7,8 +7,16 @@ readonly PROGDIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
 readonly BUILDPACKDIR="$(cd "${PROGDIR}/.." && pwd)"
 
 function main() {
+  local arch
+  arch="${GOARCH:-amd64}"
+
   while [[ "${#}" != 0 ]]; do
     case "${1}" in
+      --arch|-a)
+        arch="${2}"
+        shift 2
+        ;;
+
       --help|-h)
         shift 1
         usage
@@ -27,8 +35,8 @@ function main() {
 
   mkdir -p "${BUILDPACKDIR}/bin"
 
-  run::build
-  cmd::build
+  run::build "${arch}"
+  cmd::build "${arch}"
 }
 
 function usage() {
@@ -38,16 +46,22 @@ build.sh [OPTIONS]
 Builds the buildpack executables.
 
 OPTIONS
-  --help  -h  prints the command usage
+  --help         -h         prints the command usage
+  --arch <arch>  -a <arch>  architecture of the executables, e.g. arm64 (default: \$GOARCH or amd64)
 USAGE
 }
 
 function run::build() {
+  local arch
+  arch="${1}"
+
   if [[ -f "${BUILDPACKDIR}/run/main.go" ]]; then
     pushd "${BUILDPACKDIR}/bin" > /dev/null || return
-      printf "%s" "Building run... "
+      printf "%s" "Building run for ${arch}... "
 
       GOOS=linux \
+      GOARCH="${arch}" \
+      CGO_ENABLED=0 \
         go build \
           -ldflags="-s -w" \
           -o "run" \
@@ -67,14 +81,19 @@ function run::build() {
 }
 
 function cmd::build() {
+  local arch
+  arch="${1}"
+
   if [[ -d "${BUILDPACKDIR}/cmd" ]]; then
     local name
     for src in "${BUILDPACKDIR}"/cmd/*; do
       name="$(basename "${src}")"
 
-      printf "%s" "Building ${name}... "
+      printf "%s" "Building ${name} for ${arch}... "
 
       GOOS="linux" \
+      GOARCH="${arch}" \
+      CGO_ENABLED=0 \
         go build \
           -ldflags="-s -w" \
           -o "${BUILDPACKDIR}/bin/${name}" \
@@ -14,7 +14,8 @@ source "${ROOT_DIR}/scripts/.util/tools.sh"
 source "${ROOT_DIR}/scripts/.util/print.sh"
 
 function main {
-  local version output
+  local version output image
+  local -a archs=()
 
   while [[ "${#}" != 0 ]]; do
     case "${1}" in
@@ -28,6 +29,16 @@ function main {
         shift 2
         ;;
 
+      --arch|-a)
+        archs+=("${2}")
+        shift 2
+        ;;
+
+      --publish|-p)
+        image="${2}"
+        shift 2
+        ;;
+
       --help|-h)
         shift 1
         usage
@@ -54,9 +65,20 @@ function main {
     output="${BUILD_DIR}/buildpackage.cnb"
   fi
 
+  if [[ "${#archs[@]}" == 0 ]]; then
+    archs=(amd64 arm64)
+  fi
+
   repo::prepare
-  buildpack::archive "${version}"
-  buildpackage::create "${output}"
+
+  for arch in "${archs[@]}"; do
+    buildpack::archive "${version}" "${arch}"
+    buildpackage::create "${output%.cnb}-${arch}.cnb" "${arch}"
+  done
+
+  if [[ -n "${image:-}" ]]; then
+    buildpackage::publish "${image}" "${archs[@]}"
+  fi
 }
 
 function usage() {
@@ -68,7 +90,9 @@ Packages the buildpack into a buildpackage .cnb file.
 OPTIONS
   --help               -h            prints the command usage
   --version <version>  -v <version>  specifies the version number to use when packaging the buildpack
-  --output <output>    -o <output>   location to output the packaged buildpackage artifact (default: ${ROOT_DIR}/build/buildpackage.cnb)
+  --output <output>    -o <output>   location to output the packaged buildpackage artifacts, the architecture is appended to the name (default: ${ROOT_DIR}/build/buildpackage.cnb)
+  --arch <arch>        -a <arch>     architecture to package the buildpack for, may be given more than once (default: amd64 and arm64)
+  --publish <image>    -p <image>    publishes a multi-arch buildpackage image containing all architectures
 USAGE
 }
 
@@ -84,41 +108,74 @@ function repo::prepare() {
 }
 
 function buildpack::archive() {
-  local version
+  local version arch
   version="${1}"
+  arch="${2}"
 
-  util::print::title "Packaging buildpack into ${BUILD_DIR}/buildpack.tgz..."
+  util::print::title "Packaging buildpack for ${arch} into ${BUILD_DIR}/buildpack-${arch}.tgz..."
 
   if [[ -f "${ROOT_DIR}/.libbuildpack" ]]; then
     util::tools::packager::install --directory "${BIN_DIR}"
 
+    GOARCH="${arch}" \
     packager \
       --uncached \
       --archive \
       --version "${version}" \
-      "${BUILD_DIR}/buildpack"
+      "${BUILD_DIR}/buildpack-${arch}"
   else
     util::tools::jam::install --directory "${BIN_DIR}"
 
+    # the pre-package script builds the executables for GOARCH
+    GOARCH="${arch}" \
     jam pack \
       --buildpack "${ROOT_DIR}/buildpack.toml" \
       --version "${version}" \
-      --output "${BUILD_DIR}/buildpack.tgz"
+      --output "${BUILD_DIR}/buildpack-${arch}.tgz"
   fi
 }
 
 function buildpackage::create() {
-  local output
+  local output arch
   output="${1}"
+  arch="${2}"
 
-  util::print::title "Packaging buildpack..."
+  util::print::title "Packaging buildpack for ${arch}..."
 
   util::tools::pack::install --directory "${BIN_DIR}"
 
   pack \
     buildpack package "${output}" \
-      --path "${BUILD_DIR}/buildpack.tgz" \
+      --path "${BUILD_DIR}/buildpack-${arch}.tgz" \
+      --target "linux/${arch}" \
       --format file
 }
 
+function buildpackage::publish() {
+  local image layout
+  image="${1}"
+  shift 1
+
+  util::print::title "Publishing multi-arch buildpackage ${image}..."
+
+  # pack expects the executables of every target in <os>/<arch> next to a
+  # single buildpack.toml
+  layout="${BUILD_DIR}/multi-arch"
+  mkdir -p "${layout}"
+
+  local -a targets
+  for arch in "${@}"; do
+    mkdir -p "${layout}/linux/${arch}"
+    tar -xzf "${BUILD_DIR}/buildpack-${arch}.tgz" -C "${layout}/linux/${arch}"
+    mv "${layout}/linux/${arch}/buildpack.toml" "${layout}/buildpack.toml"
+    targets+=(--target "linux/${arch}")
+  done
+
+  pack \
+    buildpack package "${image}" \
+      --path "${layout}" \
+      "${targets[@]}" \
+      --publish
+}
+
 main "${@:-}"